@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/installer"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/kubeforge"
+)
+
+// globalOptions are the SSH, provider and output flags shared by every command
+// that connects to hosts
+type globalOptions struct {
+	fs *flag.FlagSet
+
+	configFile   string
+	clusterName  string
+	host         string
+	port         string
+	user         string
+	keyPath      string
+	password     string
+	jumpHost     string
+	provider     string
+	distribution string
+	k8sVersion   string
+	output       string
+}
+
+// newFlagSet creates the flag set of a command with the global options registered
+func newFlagSet(name, usage string) (*flag.FlagSet, *globalOptions) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s\n\nFlags:\n", programName, usage)
+		fs.PrintDefaults()
+	}
+
+	g := &globalOptions{fs: fs}
+	fs.StringVar(&g.configFile, "config", "", "Path to a JSON config file with hosts and custom steps")
+	fs.StringVar(&g.clusterName, "cluster-name", "", "Name of the cluster in kubeconfig files (default: first control plane address) and for the cloud provider (default: kubernetes)")
+	fs.StringVar(&g.host, "host", "", "Remote host IP address")
+	fs.StringVar(&g.port, "port", "22", "SSH port")
+	fs.StringVar(&g.user, "user", "", "SSH username")
+	fs.StringVar(&g.keyPath, "key", "", "Path to private key file")
+	fs.StringVar(&g.password, "password", "", "SSH password (if not using key)")
+	fs.StringVar(&g.jumpHost, "jump-host", "", "Comma-separated [user@]host[:port] bastions to reach the hosts through, like ssh -J")
+	fs.StringVar(&g.provider, "provider", string(config.Auto), "Cloud provider: auto (detected on each host), aws, gcp, azure, oracle, none (bare metal, alias baremetal)")
+	fs.StringVar(&g.distribution, "distro", "", "Linux distribution override: ubuntu, debian, centos, rhel, rocky, almalinux, fedora, amazon, oracle, sles, opensuse, flatcar (detected when empty)")
+	fs.StringVar(&g.k8sVersion, "k8s-version", config.DefaultKubernetesVersion, "Kubernetes version to install")
+	fs.StringVar(&g.output, "output", events.FormatHuman, "Progress output format: human, json (one event per line), quiet (warnings and failures only)")
+	return fs, g
+}
+
+// parse parses the command line, turning flag errors into usage errors
+func (g *globalOptions) parse(args []string) error {
+	return parseFlags(g.fs, args)
+}
+
+// isSet returns true if the flag was given on the command line
+func (g *globalOptions) isSet(name string) bool {
+	set := false
+	g.fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// spec builds the cluster spec from the config file and the flags. Flags set on the
+// command line take precedence over the config file.
+func (g *globalOptions) spec() (kubeforge.Spec, error) {
+	fileCfg := &config.FileConfig{}
+	if g.configFile != "" {
+		var err error
+		if fileCfg, err = config.LoadFile(g.configFile); err != nil {
+			return kubeforge.Spec{}, err
+		}
+	}
+
+	pick := func(name, flagValue, fileValue string) string {
+		if g.isSet(name) || fileValue == "" {
+			return flagValue
+		}
+		return fileValue
+	}
+
+	spec := kubeforge.Spec{
+		ClusterName:       pick("cluster-name", g.clusterName, fileCfg.ClusterName),
+		Provider:          pick("provider", g.provider, fileCfg.Provider),
+		Distribution:      pick("distro", g.distribution, fileCfg.Distribution),
+		KubernetesVersion: pick("k8s-version", g.k8sVersion, fileCfg.KubernetesVersion),
+		SSH: config.SSHConfig{
+			User:       pick("user", g.user, fileCfg.SSH.User),
+			Port:       pick("port", g.port, fileCfg.SSH.Port),
+			PrivateKey: pick("key", g.keyPath, fileCfg.SSH.PrivateKey),
+			Password:   pick("password", g.password, fileCfg.SSH.Password),
+			JumpHost:   pick("jump-host", g.jumpHost, fileCfg.SSH.JumpHost),
+		},
+		Hosts:     fileCfg.Hosts,
+		Steps:     fileCfg.Steps,
+		Azure:     fileCfg.Azure,
+		Oracle:    fileCfg.Oracle,
+		BareMetal: fileCfg.BareMetal,
+	}
+
+	if g.isSet("host") || len(spec.Hosts) == 0 {
+		if g.host == "" {
+			return spec, usagef("-host or a config file with hosts is required")
+		}
+		spec.Hosts = []config.HostConfig{{Address: g.host, Role: installer.RoleControlPlane}}
+	}
+	// Per-host overrides from the file give way to the command line
+	for n := range spec.Hosts {
+		if g.isSet("user") {
+			spec.Hosts[n].User = ""
+		}
+		if g.isSet("port") {
+			spec.Hosts[n].Port = ""
+		}
+		if g.isSet("jump-host") {
+			spec.Hosts[n].JumpHost = ""
+		}
+	}
+
+	return spec, nil
+}
+
+// renderer returns the event handler for the -output flag
+func (g *globalOptions) renderer(w io.Writer) (events.Handler, error) {
+	handler, err := events.NewRenderer(g.output, w)
+	if err != nil {
+		return nil, usageError{err: err}
+	}
+	return handler, nil
+}
+
+// human returns true if progress is shown as human-readable text
+func (g *globalOptions) human() bool {
+	return g.output == events.FormatHuman
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/kubeforge"
+)
+
+func TestSpecProviderSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		check  func(spec kubeforge.Spec) bool
+	}{
+		{
+			name:   "azure",
+			config: `{"hosts": [{"address": "10.0.0.4"}], "azure": {"vnetName": "cluster-vnet"}}`,
+			check: func(spec kubeforge.Spec) bool {
+				return string(spec.Azure) == `{"vnetName": "cluster-vnet"}`
+			},
+		},
+		{
+			name:   "oracle",
+			config: `{"hosts": [{"address": "10.0.0.4"}], "oracle": {"vcn": "ocid1.vcn.oc1..aaaa", "loadBalancerSubnets": ["ocid1.subnet.oc1..bbbb"]}}`,
+			check: func(spec kubeforge.Spec) bool {
+				return spec.Oracle != nil && spec.Oracle.VCN == "ocid1.vcn.oc1..aaaa" &&
+					reflect.DeepEqual(spec.Oracle.LoadBalancerSubnets, []string{"ocid1.subnet.oc1..bbbb"})
+			},
+		},
+		{
+			name:   "baremetal",
+			config: `{"hosts": [{"address": "10.0.0.4"}], "baremetal": {"interface": "eth1", "metallb": {"addresses": ["10.0.0.240-10.0.0.250"]}}}`,
+			check: func(spec kubeforge.Spec) bool {
+				return spec.BareMetal != nil && spec.BareMetal.Interface == "eth1" && spec.BareMetal.MetalLB != nil &&
+					reflect.DeepEqual(spec.BareMetal.MetalLB.Addresses, []string{"10.0.0.240-10.0.0.250"})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cluster.json")
+			if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, g := newFlagSet("install", "install [flags]")
+			if err := g.parse([]string{"-config", path}); err != nil {
+				t.Fatal(err)
+			}
+			spec, err := g.spec()
+			if err != nil {
+				t.Fatalf("spec() error = %v", err)
+			}
+			if !tt.check(spec) {
+				t.Errorf("spec() = %+v, missing the %s settings of %s", spec, tt.name, tt.config)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/installer"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/kubeconfig"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/kubeforge"
+)
+
+// runInstall installs Kubernetes on the hosts
+func runInstall(ctx context.Context, args []string) error {
+	fs, g := newFlagSet("install", "install [flags]")
+	fromStep := fs.String("from-step", "", "Resume the installation from this step, re-running it and every later step")
+	onlyStep := fs.String("only-step", "", "Run only this step, even if it was completed before")
+	parallel := fs.Bool("parallel", false, "Run each step on all of its hosts at once")
+	skipVerify := fs.Bool("skip-verify", false, "Do not wait for the nodes, system pods and a smoke-test pod afterwards")
+	verifyTimeout := fs.Duration("verify-timeout", installer.DefaultVerifyTimeout, "Time each verification phase may take")
+	ignorePreflight := fs.String("ignore-preflight", "", "Comma-separated preflight checks whose errors are only warnings, or 'all'")
+	kubeconfigPath := fs.String("kubeconfig", kubeconfig.DefaultPath(), "Merge the admin kubeconfig into this file, keeping a backup; empty to skip")
+	kubeconfigServer := fs.String("kubeconfig-server", "", "API server URL for the kubeconfig (default: https://<first control plane>:6443)")
+	// Kept from before the plan command existed
+	plan := fs.Bool("plan", false, "Same as the plan command")
+	planFormat := fs.String("plan-format", "text", "Plan output format: text, json")
+	planDetect := fs.Bool("plan-detect", false, "Connect read-only to detect the distribution, architecture and cloud provider for the plan")
+	arch := fs.String("arch", "amd64", "CPU architecture for the plan when not detected: amd64, arm64")
+	if err := g.parse(args); err != nil {
+		return err
+	}
+
+	if *plan {
+		return printPlan(ctx, g, *planFormat, *planDetect, "-plan-detect", *arch)
+	}
+
+	spec, err := g.spec()
+	if err != nil {
+		return err
+	}
+	renderer, err := g.renderer(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	// Display banner
+	if g.human() {
+		fmt.Println("==================================================")
+		fmt.Println("  Kubernetes Cloud Installer")
+		fmt.Println("  Cloud Provider:", spec.Provider)
+		fmt.Println("  Kubernetes Version:", spec.KubernetesVersion)
+		fmt.Println("==================================================")
+	}
+
+	result, err := kubeforge.Install(ctx, spec,
+		kubeforge.WithEventHandler(renderer),
+		kubeforge.WithKubeconfigServer(*kubeconfigServer),
+		kubeforge.WithFromStep(*fromStep),
+		kubeforge.WithOnlyStep(*onlyStep),
+		kubeforge.WithParallel(*parallel),
+		kubeforge.WithIgnorePreflight(splitList(*ignorePreflight)...),
+		kubeforge.WithSkipVerify(*skipVerify),
+		kubeforge.WithVerifyTimeout(*verifyTimeout))
+	if err != nil {
+		return err
+	}
+
+	// The cluster works without a local kubeconfig, so failing to write one only warns
+	merged := false
+	if *kubeconfigPath != "" && len(result.Kubeconfig) > 0 {
+		backup, err := kubeconfig.MergeFile(*kubeconfigPath, result.Kubeconfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			merged = true
+			if g.human() {
+				fmt.Printf("\nKubeconfig merged into %s\n", *kubeconfigPath)
+				if backup != "" {
+					fmt.Printf("Previous kubeconfig saved as %s\n", backup)
+				}
+			}
+		}
+	}
+	if !g.human() {
+		return nil
+	}
+
+	controlPlane := result.Hosts[0]
+	for _, h := range result.Hosts {
+		if h.Role == installer.RoleControlPlane {
+			controlPlane = h
+			break
+		}
+	}
+	fmt.Println("\n[✓] Kubernetes installation completed successfully!")
+	fmt.Println("\nTo access your Kubernetes cluster:")
+	if merged {
+		kubectl := "kubectl --kubeconfig " + *kubeconfigPath
+		if *kubeconfigPath == kubeconfig.DefaultPath() {
+			kubectl = "kubectl"
+		}
+		fmt.Println("  1. Check nodes status:  " + kubectl + " get nodes")
+		fmt.Println("  2. Deploy an application example: " + kubectl + " create deployment nginx --image=nginx")
+		fmt.Println("  3. Expose the deployment: " + kubectl + " expose deployment nginx --port=80 --type=NodePort")
+	} else {
+		fmt.Println("  1. SSH into your VM:    ssh -i", spec.SSH.PrivateKey, controlPlane.User+"@"+controlPlane.Address)
+		fmt.Println("  2. Check nodes status:  kubectl get nodes")
+		fmt.Println("  3. Deploy an application example: kubectl create deployment nginx --image=nginx")
+		fmt.Println("  4. Expose the deployment: kubectl expose deployment nginx --port=80 --type=NodePort")
+	}
+	fmt.Println("\nThank you for using Kubernetes Cloud Installer!")
+	return nil
+}
+
+// runJoin joins new nodes to a running cluster
+func runJoin(ctx context.Context, args []string) error {
+	fs, g := newFlagSet("join", "join -control-plane <address> -host <address> [flags]")
+	controlPlane := fs.String("control-plane", "", "Address of a control plane of the running cluster")
+	role := fs.String("role", installer.RoleWorker, "Role of the joining host: worker, control-plane")
+	parallel := fs.Bool("parallel", false, "Run each step on all of the joining hosts at once")
+	skipVerify := fs.Bool("skip-verify", false, "Do not wait for the nodes, system pods and a smoke-test pod afterwards")
+	verifyTimeout := fs.Duration("verify-timeout", installer.DefaultVerifyTimeout, "Time each verification phase may take")
+	ignorePreflight := fs.String("ignore-preflight", "", "Comma-separated preflight checks whose errors are only warnings, or 'all'")
+	if err := g.parse(args); err != nil {
+		return err
+	}
+
+	spec, err := g.spec()
+	if err != nil {
+		return err
+	}
+
+	// With -control-plane, -host is the joining node; otherwise the config file's
+	// first control plane is the running cluster and the other hosts join it
+	if *controlPlane != "" {
+		if !g.isSet("host") {
+			return usagef("-host is required with -control-plane")
+		}
+		spec.Hosts = []config.HostConfig{
+			{Address: *controlPlane, Role: installer.RoleControlPlane},
+			{Address: g.host, Role: *role},
+		}
+	} else if len(spec.Hosts) < 2 {
+		return usagef("-control-plane is required unless the config file lists the cluster and the joining hosts")
+	}
+
+	renderer, err := g.renderer(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	result, err := kubeforge.Join(ctx, spec,
+		kubeforge.WithEventHandler(renderer),
+		kubeforge.WithParallel(*parallel),
+		kubeforge.WithIgnorePreflight(splitList(*ignorePreflight)...),
+		kubeforge.WithSkipVerify(*skipVerify),
+		kubeforge.WithVerifyTimeout(*verifyTimeout))
+	if err != nil {
+		return err
+	}
+
+	if g.human() {
+		fmt.Println("\n[✓] Nodes joined the cluster successfully!")
+		for _, h := range result.Hosts {
+			fmt.Printf("  %s (%s)\n", h.Address, h.Role)
+		}
+	}
+	return nil
+}
+
+// runPlan prints the installation plan
+func runPlan(ctx context.Context, args []string) error {
+	fs, g := newFlagSet("plan", "plan -detect [flags]\n       "+programName+" plan -provider=<name> -distro=<name> [flags]")
+	format := fs.String("format", "text", "Plan output format: text, json")
+	detect := fs.Bool("detect", false, "Connect read-only to detect the distribution, architecture and cloud provider")
+	arch := fs.String("arch", "amd64", "CPU architecture when not detected: amd64, arm64")
+	if err := g.parse(args); err != nil {
+		return err
+	}
+	return printPlan(ctx, g, *format, *detect, "-detect", *arch)
+}
+
+// printPlan writes the installation plan without changing the remote hosts. Without
+// detection, detectFlag names the flag that turns it on in the error for a missing
+// provider or distribution.
+func printPlan(ctx context.Context, g *globalOptions, format string, detect bool, detectFlag, arch string) error {
+	if format != "text" && format != "json" {
+		return usagef("invalid plan format '%s': use text or json", format)
+	}
+	parsedArch, err := config.ParseArchitecture(arch)
+	if err != nil {
+		return usageError{err: err}
+	}
+
+	spec, err := g.spec()
+	if err != nil {
+		return err
+	}
+	if !detect {
+		if spec.Provider == "" || spec.Provider == string(config.Auto) {
+			return usagef("the provider cannot be detected without %s: set -provider to aws, gcp, azure, oracle or none, or add %s", detectFlag, detectFlag)
+		}
+		if spec.Distribution == "" {
+			return usagef("the distribution cannot be detected without %s: set -distro, or add %s", detectFlag, detectFlag)
+		}
+	}
+	// The plan owns stdout, so its progress goes to stderr
+	renderer, err := g.renderer(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	plans, err := kubeforge.Plan(ctx, spec,
+		kubeforge.WithDetect(detect),
+		kubeforge.WithArch(parsedArch),
+		kubeforge.WithEventHandler(renderer))
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plans)
+	}
+
+	for n, plan := range plans {
+		if n > 0 {
+			fmt.Println()
+		}
+		plan.WriteText(os.Stdout)
+	}
+	return nil
+}
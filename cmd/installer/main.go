@@ -1,79 +1,127 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
-	"github.com/ochestra-tech/kubeforge-cli/pkg/installer"
-	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
-	"log"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
 )
 
+// programName is the name of the installed binary
+const programName = "kubeopera-cli"
+
+// Exit codes shared by every command
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands lists the subcommands in the order shown in the usage
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"install", "Install Kubernetes on the hosts (default when no command is given)", runInstall},
+		{"join", "Join additional nodes to a running cluster", runJoin},
+		{"reset", "Revert an installation on the hosts", runReset},
+		{"upgrade", "Upgrade the cluster to a new Kubernetes version", runUpgrade},
+		{"status", "Show the cluster nodes and the installation state of the hosts", runStatus},
+		{"kubeconfig", "Fetch the cluster's admin kubeconfig", runKubeconfig},
+		{"proxy", "Keep an SSH tunnel to the API server open for local kubectl", runProxy},
+		{"plan", "Print every remote command and file of an installation without running it", runPlan},
+		{"version", "Print the version", runVersion},
+		{"completion", "Print a shell completion script", runCompletion},
+	}
+}
+
+// usageError is returned for invalid command lines
+type usageError struct {
+	err error
+	// reported is set when the flag package already printed the error and usage
+	reported bool
+}
+
+func (e usageError) Error() string {
+	return e.err.Error()
+}
+
+// usagef returns a usage error
+func usagef(format string, args ...interface{}) error {
+	return usageError{err: fmt.Errorf(format, args...)}
+}
+
 func main() {
-	// Parse command line arguments
-	host := flag.String("host", "", "Remote host IP address")
-	port := flag.String("port", "22", "SSH port")
-	user := flag.String("user", "", "SSH username")
-	keyPath := flag.String("key", "", "Path to private key file")
-	password := flag.String("password", "", "SSH password (if not using key)")
-	provider := flag.String("provider", "aws", "Cloud provider: aws, gcp, azure, oracle")
-	distribution := flag.String("distro", "", "Linux distribution: ubuntu, centos, amazon, oracle")
-
-	flag.Parse()
-
-	// Create configuration from flags
-	cfg, err := config.NewConfig(*host, *port, *user, *keyPath, *password, *provider, *distribution)
-	if err != nil {
-		log.Fatalf("Failed to create configuration: %v", err)
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes the command line and returns the exit code
+func run(args []string) int {
+	// The flags of the original single-command CLI still mean install
+	name := "install"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	// Display banner
-	fmt.Println("==================================================")
-	fmt.Println("  Kubernetes Cloud Installer")
-	fmt.Println("  Cloud Provider:", cfg.Provider)
-	fmt.Println("  Linux Distribution:", cfg.Distribution)
-	fmt.Println("==================================================")
-
-	// Create SSH client
-	sshClient, err := ssh.NewClient(cfg)
-	if err != nil {
-		log.Fatalf("Failed to create SSH client: %v", err)
+	if name == "help" {
+		printUsage(os.Stdout)
+		return exitOK
 	}
-	defer sshClient.Close()
-
-	fmt.Println("Connected to remote host successfully")
-
-	// Create installer
-	k8sInstaller := installer.NewInstaller(sshClient, cfg)
-
-	// Run installation steps
-	steps := []struct {
-		name string
-		fn   func() error
-	}{
-		{"Installing prerequisites", k8sInstaller.InstallPrerequisites},
-		{"Installing container runtime", k8sInstaller.InstallContainerRuntime},
-		{"Installing Kubernetes components", k8sInstaller.InstallKubernetesComponents},
-		{"Initializing Kubernetes cluster", k8sInstaller.InitializeCluster},
-		{"Configuring cloud provider integration", k8sInstaller.SetupCloudProviderIntegration},
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Error: unknown command '%s'\n\n", name)
+		printUsage(os.Stderr)
+		return exitUsage
 	}
 
-	for _, step := range steps {
-		fmt.Printf("\n[*] %s...\n", step.name)
-		if err := step.fn(); err != nil {
-			log.Fatalf("Failed to %s: %v", step.name, err)
-		}
-		fmt.Printf("[✓] %s completed successfully\n", step.name)
+	// Cancel between steps on Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := cmd.run(ctx, args)
+	var usageErr usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usageErr) && usageErr.reported:
+		return exitUsage
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Run '%s %s -h' for usage.\n", programName, name)
+		return exitUsage
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailure
 	}
+}
 
-	// Display cloud provider-specific information
-	k8sInstaller.DisplayCloudProviderInfo()
+// findCommand returns the subcommand with the name, or nil
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
 
-	fmt.Println("\n[✓] Kubernetes installation completed successfully!")
-	fmt.Println("\nTo access your Kubernetes cluster:")
-	fmt.Println("  1. SSH into your VM:    ssh -i", *keyPath, *user+"@"+*host)
-	fmt.Println("  2. Check nodes status:  kubectl get nodes")
-	fmt.Println("  3. Deploy an application example: kubectl create deployment nginx --image=nginx")
-	fmt.Println("  4. Expose the deployment: kubectl expose deployment nginx --port=80 --type=NodePort")
-	fmt.Println("\nThank you for using Kubernetes Cloud Installer!")
+// printUsage lists the subcommands
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", programName)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", programName)
 }
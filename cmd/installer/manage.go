@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/installer"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/kubeconfig"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/kubeforge"
+)
+
+// runReset reverts the installation on the hosts
+func runReset(ctx context.Context, args []string) error {
+	fs, g := newFlagSet("reset", "reset [flags]")
+	yes := fs.Bool("yes", false, "Do not ask for confirmation")
+	controlPlane := fs.String("control-plane", "", "Address of a control plane that drains and deletes -host and is itself kept")
+	uninstall := fs.Bool("uninstall", false, "Also remove the Kubernetes and container runtime packages and repositories")
+	revertSystem := fs.Bool("revert-system", false, "Also remove the kernel module and sysctl settings")
+	if err := g.parse(args); err != nil {
+		return err
+	}
+
+	spec, err := g.spec()
+	if err != nil {
+		return err
+	}
+
+	// With -control-plane only -host is reset, like a join in reverse
+	resetHosts := spec.Hosts
+	if *controlPlane != "" {
+		if !g.isSet("host") {
+			return usagef("-host is required with -control-plane")
+		}
+		spec.Hosts = []config.HostConfig{
+			{Address: *controlPlane, Role: installer.RoleControlPlane},
+			{Address: g.host, Role: installer.RoleWorker},
+		}
+		resetHosts = spec.Hosts[1:]
+	}
+
+	renderer, err := g.renderer(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if !*yes {
+		var addresses []string
+		for _, h := range resetHosts {
+			addresses = append(addresses, h.Address)
+		}
+		if !confirm(fmt.Sprintf("This removes Kubernetes from %s. Continue?", strings.Join(addresses, ", "))) {
+			return fmt.Errorf("reset cancelled")
+		}
+	}
+
+	_, err = kubeforge.Reset(ctx, spec,
+		kubeforge.WithEventHandler(renderer),
+		kubeforge.WithKeepControlPlane(*controlPlane != ""),
+		kubeforge.WithUninstall(*uninstall),
+		kubeforge.WithRevertSystem(*revertSystem))
+	if err != nil {
+		return err
+	}
+	if g.human() {
+		fmt.Println("\n[✓] Reset completed successfully!")
+	}
+	return nil
+}
+
+// runUpgrade upgrades the cluster to the -k8s-version
+func runUpgrade(ctx context.Context, args []string) error {
+	_, g := newFlagSet("upgrade", "upgrade -k8s-version <version> [flags]")
+	if err := g.parse(args); err != nil {
+		return err
+	}
+
+	spec, err := g.spec()
+	if err != nil {
+		return err
+	}
+	// The default version is not a meaningful upgrade target
+	if !g.isSet("k8s-version") && g.configFile == "" {
+		return usagef("-k8s-version is required")
+	}
+	renderer, err := g.renderer(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if _, err := kubeforge.Upgrade(ctx, spec, kubeforge.WithEventHandler(renderer)); err != nil {
+		return err
+	}
+	if g.human() {
+		fmt.Printf("\n[✓] Cluster upgraded to v%s successfully!\n", strings.TrimPrefix(spec.KubernetesVersion, "v"))
+	}
+	return nil
+}
+
+// runStatus shows the cluster nodes and the installation state of the hosts
+func runStatus(ctx context.Context, args []string) error {
+	_, g := newFlagSet("status", "status [flags]")
+	if err := g.parse(args); err != nil {
+		return err
+	}
+
+	spec, err := g.spec()
+	if err != nil {
+		return err
+	}
+	// Only warnings are of interest while collecting the status
+	renderer, err := g.renderer(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	status, err := kubeforge.GetStatus(ctx, spec, kubeforge.WithEventHandler(func(e events.Event) {
+		if e.Type == events.Warning {
+			renderer(e)
+		}
+	}))
+	if err != nil {
+		return err
+	}
+
+	if g.output == events.FormatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tSTATUS\tROLES\tVERSION\tINTERNAL-IP")
+	for _, node := range status.Nodes {
+		state := "NotReady"
+		if node.Ready {
+			state = "Ready"
+		}
+		if node.Unschedulable {
+			state += ",SchedulingDisabled"
+		}
+		roles := strings.Join(node.Roles, ",")
+		if roles == "" {
+			roles = "<none>"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", node.Name, state, roles, node.KubeletVersion, node.InternalIP)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "HOST\tROLE\tKUBERNETES\tCOMPLETED STEPS")
+	for _, h := range status.Hosts {
+		completed := strings.Join(h.Completed, ",")
+		if h.Error != "" {
+			completed = "error: " + h.Error
+		} else if completed == "" {
+			completed = "<none>"
+		}
+		version := "-"
+		if h.KubernetesVersion != "" {
+			version = "v" + h.KubernetesVersion
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", h.Address, h.Role, version, completed)
+	}
+	return w.Flush()
+}
+
+// runKubeconfig fetches the admin kubeconfig
+func runKubeconfig(ctx context.Context, args []string) error {
+	fs, g := newFlagSet("kubeconfig", "kubeconfig [-o <file>] [-merge] [flags]")
+	outputFile := fs.String("o", "", "Write the kubeconfig to this file instead of stdout, keeping a backup")
+	merge := fs.Bool("merge", false, "Merge into the -o file, or the default kubeconfig, instead of replacing it")
+	server := fs.String("server", "", "API server URL for the kubeconfig (default: https://<first control plane>:6443)")
+	tunnel := fs.Bool("tunnel", false, "Reach the API server through an SSH tunnel that stays open until Ctrl-C, like the proxy command")
+	if err := g.parse(args); err != nil {
+		return err
+	}
+
+	if *tunnel {
+		if *merge || *server != "" {
+			return usagef("-tunnel cannot be combined with -merge or -server")
+		}
+		path := *outputFile
+		if path == "" {
+			path = defaultTunnelKubeconfig()
+		}
+		return serveTunnel(ctx, g, defaultTunnelListen, path)
+	}
+
+	spec, err := g.spec()
+	if err != nil {
+		return err
+	}
+
+	data, err := kubeforge.Kubeconfig(ctx, spec, kubeforge.WithKubeconfigServer(*server))
+	if err != nil {
+		return err
+	}
+
+	path := *outputFile
+	if path == "" && *merge {
+		path = kubeconfig.DefaultPath()
+	}
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	var backup string
+	if *merge {
+		backup, err = kubeconfig.MergeFile(path, data)
+	} else {
+		var c *kubeconfig.Config
+		if c, err = kubeconfig.Parse(data); err == nil {
+			backup, err = kubeconfig.WriteFile(path, c)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Kubeconfig written to %s\n", path)
+	if backup != "" {
+		fmt.Fprintf(os.Stderr, "Previous kubeconfig saved as %s\n", backup)
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/kubeconfig"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/kubeforge"
+)
+
+// defaultTunnelListen avoids the API server port, which a local cluster may use
+const defaultTunnelListen = "127.0.0.1:16443"
+
+// runProxy keeps an SSH tunnel to the API server open
+func runProxy(ctx context.Context, args []string) error {
+	fs, g := newFlagSet("proxy", "proxy [-listen <address>] [-o <file>] [flags]")
+	listen := fs.String("listen", defaultTunnelListen, "Local address of the tunnel")
+	outputFile := fs.String("o", defaultTunnelKubeconfig(), "Write a kubeconfig pointing at the tunnel to this file")
+	if err := g.parse(args); err != nil {
+		return err
+	}
+	return serveTunnel(ctx, g, *listen, *outputFile)
+}
+
+// defaultTunnelKubeconfig returns the kubeconfig written for tunnels, next to the default one
+func defaultTunnelKubeconfig() string {
+	return filepath.Join(filepath.Dir(kubeconfig.DefaultPath()), "kubeforge-tunnel")
+}
+
+// serveTunnel opens the tunnel, writes its kubeconfig and waits for Ctrl-C. The
+// kubeconfig is removed when the tunnel closes since it is useless without it.
+func serveTunnel(ctx context.Context, g *globalOptions, listen, path string) error {
+	spec, err := g.spec()
+	if err != nil {
+		return err
+	}
+	// Progress goes to stderr so that stdout stays clean for scripts
+	renderer, err := g.renderer(os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	tunnel, err := kubeforge.OpenTunnel(ctx, spec, listen, kubeforge.WithEventHandler(renderer))
+	if err != nil {
+		return err
+	}
+	defer tunnel.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create kubeconfig directory: %v", err)
+	}
+	if err := os.WriteFile(path, tunnel.Kubeconfig, 0600); err != nil {
+		return fmt.Errorf("failed to write kubeconfig: %v", err)
+	}
+	defer os.Remove(path)
+
+	fmt.Fprintf(os.Stderr, "Tunnel open on %s. In another shell:\n\n", tunnel.Address)
+	fmt.Fprintf(os.Stderr, "  export KUBECONFIG=%s\n  kubectl get nodes\n\n", path)
+	fmt.Fprintln(os.Stderr, "Press Ctrl-C to close the tunnel.")
+
+	<-ctx.Done()
+	fmt.Fprintln(os.Stderr, "\nTunnel closed.")
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+)
+
+// version is set at build time with -ldflags "-X main.version=<version>"
+var version = "dev"
+
+// runVersion prints the version
+func runVersion(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s\n", programName, version)
+	fmt.Printf("Default Kubernetes version: v%s\n", config.DefaultKubernetesVersion)
+	return nil
+}
+
+// runCompletion prints a shell completion script
+func runCompletion(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("completion", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s completion bash|zsh|fish\n", programName)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError{err: err, reported: true}
+	}
+	if fs.NArg() != 1 {
+		return usagef("a shell is required: bash, zsh, or fish")
+	}
+
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	words := strings.Join(names, " ")
+
+	switch fs.Arg(0) {
+	case "bash":
+		fmt.Printf(`_%[1]s() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W "%[2]s" -- "$cur"))
+    else
+        COMPREPLY=($(compgen -W "$(%[3]s ${COMP_WORDS[1]} -h 2>&1 | awk '/^  -/ {print $1}')" -- "$cur"))
+    fi
+}
+complete -o default -F _%[1]s %[3]s
+`, strings.ReplaceAll(programName, "-", "_"), words, programName)
+	case "zsh":
+		fmt.Printf(`#compdef %[1]s
+_%[2]s() {
+    if (( CURRENT == 2 )); then
+        compadd %[3]s
+    else
+        compadd -- $(%[1]s ${words[2]} -h 2>&1 | awk '/^  -/ {print $1}')
+    fi
+}
+compdef _%[2]s %[1]s
+`, programName, strings.ReplaceAll(programName, "-", "_"), words)
+	case "fish":
+		fmt.Printf("complete -c %s -f -n '__fish_use_subcommand' -a '%s'\n", programName, words)
+	default:
+		return usagef("unsupported shell '%s': use bash, zsh, or fish", fs.Arg(0))
+	}
+	return nil
+}
+
+// parseFlags parses a flag set without global options
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError{err: err, reported: true}
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected argument '%s'", fs.Arg(0))
+	}
+	return nil
+}
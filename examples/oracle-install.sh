@@ -7,6 +7,8 @@
 HOST="<ORACLE_VM_IP_ADDRESS>"  # Replace with your Oracle Cloud VM IP address
 KEY_PATH="~/.ssh/oracle-key.pem"  # Replace with your SSH key path
 USER="opc"  # Default user for Oracle Linux, use "ubuntu" for Ubuntu
+VCN_ID="<VCN_OCID>"  # Replace with the OCID of the cluster's VCN
+SUBNET_ID="<SUBNET_OCID>"  # Replace with the OCID of the load balancer subnet
 
 # Print banner
 echo "================================================"
@@ -26,7 +28,11 @@ fi
 
 # Run the installer
 echo "Starting Kubernetes installation on Oracle Cloud..."
-./kubeforge-cli -host="$HOST" -key="$KEY_PATH" -user="$USER" -provider=oracle
+# The metadata service does not name the VCN and subnet, so they go in a config file
+cat > oracle.json <<EOF
+{"oracle": {"vcn": "$VCN_ID", "loadBalancerSubnets": ["$SUBNET_ID"]}}
+EOF
+./kubeforge-cli -host="$HOST" -key="$KEY_PATH" -user="$USER" -provider=oracle -config=oracle.json
 
 echo
 echo "Installation complete!"
@@ -4,6 +4,9 @@ go 1.23.0
 
 toolchain go1.23.9
 
-require golang.org/x/crypto v0.38.0
+require (
+	golang.org/x/crypto v0.38.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require golang.org/x/sys v0.33.0 // indirect
@@ -2,7 +2,11 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/packages"
 )
 
 // CloudProvider represents the type of cloud provider
@@ -13,17 +17,43 @@ const (
 	GCP    CloudProvider = "gcp"
 	Azure  CloudProvider = "azure"
 	Oracle CloudProvider = "oracle"
+
+	// None installs without cloud integration, on bare metal or private clouds.
+	// "baremetal" is accepted as another name for it.
+	None CloudProvider = "none"
+
+	// Auto is replaced by the provider detected on the host after connecting
+	Auto CloudProvider = "auto"
 )
 
+// DefaultKubernetesVersion is the Kubernetes release installed when none is specified
+const DefaultKubernetesVersion = "1.33.4"
+
 // Config stores the connection and installation configuration
 type Config struct {
-	Host         string
-	Port         string
-	User         string
-	PrivateKey   string
-	Password     string
+	Host       string
+	Port       string
+	User       string
+	PrivateKey string
+	Password   string
+	// JumpHosts are "[user@]host[:port]" bastions the host is reached through, in order
+	JumpHosts    []string
 	Provider     CloudProvider
-	Distribution string
+	Distribution Distribution
+	// KubernetesVersion is the pinned Kubernetes release without the "v" prefix
+	KubernetesVersion string
+	// DistributionVersion and Arch are filled in by platform detection after connecting
+	DistributionVersion string
+	Arch                Architecture
+	// ClusterName identifies the cluster to the cloud provider, such as in the
+	// "kubernetes.io/cluster/<name>" tags of AWS resources
+	ClusterName string
+	// Azure overrides discovered azure.json settings, as a JSON object
+	Azure json.RawMessage
+	// Oracle holds the OCI cloud controller manager network settings
+	Oracle *OracleConfig
+	// BareMetal configures the node address and load balancers of the none provider
+	BareMetal *BareMetalConfig
 }
 
 // NewConfig creates a new configuration with validation and defaults
@@ -32,14 +62,13 @@ func NewConfig(host, port, user, keyPath, password, provider, distribution strin
 		return nil, fmt.Errorf("host IP address is required")
 	}
 
-	if keyPath == "" && password == "" {
-		return nil, fmt.Errorf("either private key or password is required")
-	}
-
 	// Validate cloud provider
 	cloudProvider := CloudProvider(provider)
+	if provider == "baremetal" {
+		cloudProvider = None
+	}
 	if !isValidProvider(cloudProvider) {
-		return nil, fmt.Errorf("invalid cloud provider '%s': use aws, gcp, azure, or oracle", provider)
+		return nil, fmt.Errorf("invalid cloud provider '%s': use auto, aws, gcp, azure, oracle, or none", provider)
 	}
 
 	// Set default user based on provider if not specified
@@ -48,30 +77,56 @@ func NewConfig(host, port, user, keyPath, password, provider, distribution strin
 		username = getDefaultUser(cloudProvider, distribution)
 	}
 
-	// Set default distribution if not specified
-	distro := distribution
-	if distro == "" {
-		distro = getDefaultDistribution(cloudProvider)
+	// Validate distribution override; when empty it is detected after connecting
+	distro := Distribution(distribution)
+	if distro != "" {
+		if _, ok := distributions[distro]; !ok {
+			return nil, fmt.Errorf("invalid distribution '%s': use ubuntu, debian, centos, rhel, rocky, almalinux, fedora, amazon, oracle, sles, opensuse, or flatcar", distribution)
+		}
 	}
 
 	return &Config{
-		Host:         host,
-		Port:         port,
-		User:         username,
-		PrivateKey:   keyPath,
-		Password:     password,
-		Provider:     cloudProvider,
-		Distribution: distro,
+		Host:              host,
+		Port:              port,
+		User:              username,
+		PrivateKey:        keyPath,
+		Password:          password,
+		Provider:          cloudProvider,
+		Distribution:      distro,
+		KubernetesVersion: DefaultKubernetesVersion,
 	}, nil
 }
 
+// SetKubernetesVersion validates and sets the Kubernetes version to install
+func (c *Config) SetKubernetesVersion(version string) error {
+	version = strings.TrimPrefix(version, "v")
+	if len(strings.Split(version, ".")) != 3 {
+		return fmt.Errorf("invalid Kubernetes version '%s': use a full release such as %s", version, DefaultKubernetesVersion)
+	}
+	c.KubernetesVersion = version
+	return nil
+}
+
+// KubernetesMinorVersion returns the Kubernetes minor release, e.g. "v1.33"
+func (c *Config) KubernetesMinorVersion() string {
+	parts := strings.Split(c.KubernetesVersion, ".")
+	if len(parts) < 2 {
+		return "v" + c.KubernetesVersion
+	}
+	return "v" + parts[0] + "." + parts[1]
+}
+
 // isValidProvider checks if the provided cloud provider is valid
 func isValidProvider(provider CloudProvider) bool {
-	return provider == AWS || provider == GCP || provider == Azure || provider == Oracle
+	return provider == Auto || provider == AWS || provider == GCP || provider == Azure || provider == Oracle || provider == None
 }
 
 // getDefaultUser returns the default SSH user for the given cloud provider and distribution
 func getDefaultUser(provider CloudProvider, distribution string) string {
+	if distribution == string(Flatcar) {
+		return "core"
+	}
+
 	switch provider {
 	case AWS:
 		if distribution == "ubuntu" {
@@ -93,59 +148,103 @@ func getDefaultUser(provider CloudProvider, distribution string) string {
 			return "ubuntu"
 		}
 		return "opc"
-	default:
+	case Auto:
+		// The cloud is only known after connecting; most clouds' Ubuntu images use ubuntu
+		if distribution == "ubuntu" {
+			return "ubuntu"
+		}
 		return "root"
-	}
-}
-
-// getDefaultDistribution returns the default distribution for the given cloud provider
-func getDefaultDistribution(provider CloudProvider) string {
-	switch provider {
-	case AWS:
-		return "amazon"
-	case GCP:
-		return "debian"
-	case Azure:
-		return "ubuntu"
-	case Oracle:
-		return "oracle"
 	default:
-		return "ubuntu"
+		return "root"
 	}
 }
 
 // IsDebianBased returns true if the distribution is Debian-based
 func (c *Config) IsDebianBased() bool {
-	return c.Distribution == "ubuntu" || c.Distribution == "debian"
+	return distributions[c.Distribution].family == DebianFamily
 }
 
 // IsRHELBased returns true if the distribution is RHEL-based
 func (c *Config) IsRHELBased() bool {
-	return c.Distribution == "centos" || c.Distribution == "rhel" ||
-		c.Distribution == "amazon" || c.Distribution == "oracle"
+	return distributions[c.Distribution].family == RHELFamily
 }
 
-// GetPackageManager returns the appropriate package manager commands for the distribution
-func (c *Config) GetPackageManager() map[string]string {
-	switch {
-	case c.IsDebianBased():
-		return map[string]string{
-			"update":     "sudo apt-get update",
-			"install":    "sudo apt-get install -y",
-			"repository": "sudo apt-add-repository",
-		}
-	case c.IsRHELBased():
-		return map[string]string{
-			"update":     "sudo yum update -y",
-			"install":    "sudo yum install -y",
-			"repository": "sudo yum-config-manager --add-repo",
+// IsSUSEBased returns true if the distribution is SUSE-based
+func (c *Config) IsSUSEBased() bool {
+	return distributions[c.Distribution].family == SUSEFamily
+}
+
+// IsImmutable returns true if the distribution has a read-only /usr and Kubernetes
+// has to be installed from binaries instead of packages
+func (c *Config) IsImmutable() bool {
+	return distributions[c.Distribution].family == ImmutableFamily
+}
+
+// UsesDNF returns true if the distribution release manages packages with dnf
+func (c *Config) UsesDNF() bool {
+	spec := distributions[c.Distribution]
+	if spec.dnfSince == "" {
+		return false
+	}
+	// Unknown versions (derivatives) assume a current release
+	return c.DistributionVersion == "" || compareVersions(c.DistributionVersion, spec.dnfSince) >= 0
+}
+
+// ContainerdRepository returns the download.docker.com repository providing containerd.io,
+// or nil when the distribution ships containerd in its own repositories
+func (c *Config) ContainerdRepository() *packages.Repository {
+	spec := distributions[c.Distribution]
+	if spec.dockerRepo == "" {
+		return nil
+	}
+
+	baseURL := "https://download.docker.com/linux/" + spec.dockerRepo
+	if c.IsDebianBased() {
+		return &packages.Repository{
+			Name:       "docker",
+			URL:        baseURL,
+			GPGKeyURL:  baseURL + "/gpg",
+			Suite:      "$(lsb_release -cs)",
+			Components: []string{"stable"},
+			Arch:       string(c.Arch),
 		}
-	default:
-		// Default to Ubuntu
-		return map[string]string{
-			"update":     "sudo apt-get update",
-			"install":    "sudo apt-get install -y",
-			"repository": "sudo apt-add-repository",
+	}
+
+	return &packages.Repository{
+		Name:      "docker-ce",
+		URL:       baseURL + "/$releasever/$basearch/stable",
+		GPGKeyURL: baseURL + "/gpg",
+	}
+}
+
+// KubernetesRepository returns the pkgs.k8s.io repository for the configured minor version
+func (c *Config) KubernetesRepository() *packages.Repository {
+	baseURL := "https://pkgs.k8s.io/core:/stable:/" + c.KubernetesMinorVersion()
+	if c.IsDebianBased() {
+		return &packages.Repository{
+			Name:      "kubernetes",
+			URL:       baseURL + "/deb/",
+			GPGKeyURL: baseURL + "/deb/Release.key",
 		}
 	}
+
+	return &packages.Repository{
+		Name:      "kubernetes",
+		URL:       baseURL + "/rpm/",
+		GPGKeyURL: baseURL + "/rpm/repodata/repomd.xml.key",
+	}
+}
+
+// GetPackageManager returns the package manager for the distribution. Immutable
+// distributions have none.
+func (c *Config) GetPackageManager() (packages.Manager, error) {
+	name := distributions[c.Distribution].packageManager
+	if name == "yum" && c.UsesDNF() {
+		name = "dnf"
+	}
+	pm, err := packages.ForName(name)
+	if err != nil {
+		return nil, fmt.Errorf("distribution %s: %v", c.Distribution, err)
+	}
+	return pm, nil
 }
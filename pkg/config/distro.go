@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Distribution represents a supported Linux distribution
+type Distribution string
+
+const (
+	Ubuntu      Distribution = "ubuntu"
+	Debian      Distribution = "debian"
+	CentOS      Distribution = "centos"
+	RHEL        Distribution = "rhel"
+	AmazonLinux Distribution = "amazon"
+	OracleLinux Distribution = "oracle"
+	Rocky       Distribution = "rocky"
+	AlmaLinux   Distribution = "almalinux"
+	Fedora      Distribution = "fedora"
+	SLES        Distribution = "sles"
+	OpenSUSE    Distribution = "opensuse"
+	Flatcar     Distribution = "flatcar"
+)
+
+// Family groups distributions that share packaging and system layout
+type Family string
+
+const (
+	DebianFamily Family = "debian"
+	RHELFamily   Family = "rhel"
+	SUSEFamily   Family = "suse"
+	// ImmutableFamily distributions have a read-only /usr and no package manager
+	ImmutableFamily Family = "immutable"
+)
+
+// Architecture represents a CPU architecture in Kubernetes/Go naming
+type Architecture string
+
+const (
+	AMD64 Architecture = "amd64"
+	ARM64 Architecture = "arm64"
+)
+
+// distroSpec describes how a distribution is supported
+type distroSpec struct {
+	family         Family
+	minVersion     string
+	packageManager string
+	// dnfSince is the first release that uses dnf; empty means dnf is never used
+	dnfSince string
+	// dockerRepo is the download.docker.com repository providing containerd.io;
+	// empty means containerd comes from the distribution's own repositories
+	dockerRepo string
+}
+
+// distributions lists every supported distribution
+var distributions = map[Distribution]distroSpec{
+	Ubuntu:      {family: DebianFamily, minVersion: "20.04", packageManager: "apt", dockerRepo: "ubuntu"},
+	Debian:      {family: DebianFamily, minVersion: "10", packageManager: "apt", dockerRepo: "debian"},
+	CentOS:      {family: RHELFamily, minVersion: "7", packageManager: "yum", dnfSince: "8", dockerRepo: "centos"},
+	RHEL:        {family: RHELFamily, minVersion: "8", packageManager: "yum", dnfSince: "8", dockerRepo: "rhel"},
+	AmazonLinux: {family: RHELFamily, minVersion: "2", packageManager: "yum", dnfSince: "2023"},
+	OracleLinux: {family: RHELFamily, minVersion: "8", packageManager: "yum", dnfSince: "8", dockerRepo: "centos"},
+	Rocky:       {family: RHELFamily, minVersion: "8", packageManager: "yum", dnfSince: "8", dockerRepo: "centos"},
+	AlmaLinux:   {family: RHELFamily, minVersion: "8", packageManager: "yum", dnfSince: "8", dockerRepo: "centos"},
+	Fedora:      {family: RHELFamily, minVersion: "39", packageManager: "yum", dnfSince: "1", dockerRepo: "fedora"},
+	SLES:        {family: SUSEFamily, minVersion: "15", packageManager: "zypper"},
+	OpenSUSE:    {family: SUSEFamily, minVersion: "15", packageManager: "zypper"},
+	Flatcar:     {family: ImmutableFamily, minVersion: "3510"},
+}
+
+// osReleaseIDs maps /etc/os-release ID values to distributions
+var osReleaseIDs = map[string]Distribution{
+	"ubuntu":    Ubuntu,
+	"debian":    Debian,
+	"centos":    CentOS,
+	"rhel":      RHEL,
+	"amzn":      AmazonLinux,
+	"ol":        OracleLinux,
+	"rocky":     Rocky,
+	"almalinux": AlmaLinux,
+	"fedora":    Fedora,
+	"sles":      SLES,
+	"sles_sap":  SLES,
+	// Leap and Tumbleweed share one definition; Tumbleweed versions are snapshot dates
+	"opensuse-leap":       OpenSUSE,
+	"opensuse-tumbleweed": OpenSUSE,
+	"opensuse":            OpenSUSE,
+	"flatcar":             Flatcar,
+}
+
+// OSRelease holds the fields of /etc/os-release used for detection
+type OSRelease struct {
+	ID         string
+	IDLike     []string
+	VersionID  string
+	PrettyName string
+}
+
+// ParseOSRelease parses the contents of an /etc/os-release file
+func ParseOSRelease(content string) *OSRelease {
+	release := &OSRelease{}
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, "\"'")
+
+		switch key {
+		case "ID":
+			release.ID = strings.ToLower(value)
+		case "ID_LIKE":
+			release.IDLike = strings.Fields(strings.ToLower(value))
+		case "VERSION_ID":
+			release.VersionID = value
+		case "PRETTY_NAME":
+			release.PrettyName = value
+		}
+	}
+
+	return release
+}
+
+// Distribution maps the release to a supported distribution, falling back to ID_LIKE
+// for derivatives. The second return value is false when the ID matched only via ID_LIKE.
+func (r *OSRelease) Distribution() (Distribution, bool, error) {
+	if distro, ok := osReleaseIDs[r.ID]; ok {
+		return distro, true, nil
+	}
+
+	for _, like := range r.IDLike {
+		if distro, ok := osReleaseIDs[like]; ok {
+			return distro, false, nil
+		}
+	}
+
+	return "", false, fmt.Errorf("unsupported distribution '%s' (%s)", r.ID, r.PrettyName)
+}
+
+// ParseArchitecture maps the output of `uname -m` to an architecture
+func ParseArchitecture(machine string) (Architecture, error) {
+	switch strings.TrimSpace(machine) {
+	case "x86_64", "amd64":
+		return AMD64, nil
+	case "aarch64", "arm64":
+		return ARM64, nil
+	default:
+		return "", fmt.Errorf("unsupported architecture '%s': use amd64 or arm64", strings.TrimSpace(machine))
+	}
+}
+
+// ValidatePlatform checks that the distribution, version and architecture are supported
+func ValidatePlatform(distro Distribution, version string, arch Architecture) error {
+	spec, ok := distributions[distro]
+	if !ok {
+		return fmt.Errorf("unsupported distribution '%s'", distro)
+	}
+
+	if arch != AMD64 && arch != ARM64 {
+		return fmt.Errorf("unsupported architecture '%s': use amd64 or arm64", arch)
+	}
+
+	if version != "" && compareVersions(version, spec.minVersion) < 0 {
+		return fmt.Errorf("%s %s is not supported: version %s or later is required", distro, version, spec.minVersion)
+	}
+
+	if distro == CentOS && arch == ARM64 && version != "" && compareVersions(version, "8") < 0 {
+		return fmt.Errorf("centos %s is not supported on arm64", version)
+	}
+
+	return nil
+}
+
+// compareVersions compares two dotted numeric versions, returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	partsA := strings.Split(a, ".")
+	partsB := strings.Split(b, ".")
+
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var numA, numB int
+		if i < len(partsA) {
+			numA, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			numB, _ = strconv.Atoi(partsB[i])
+		}
+
+		if numA < numB {
+			return -1
+		}
+		if numA > numB {
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileConfig is the JSON configuration file accepted with -config
+type FileConfig struct {
+	// ClusterName names the cluster, context and user in kubeconfig files; it defaults
+	// to the address of the first control plane. Cloud providers identify the cluster
+	// by it too, defaulting to "kubernetes".
+	ClusterName       string       `json:"clusterName"`
+	Provider          string       `json:"provider"`
+	Distribution      string       `json:"distro"`
+	KubernetesVersion string       `json:"kubernetesVersion"`
+	SSH               SSHConfig    `json:"ssh"`
+	Hosts             []HostConfig `json:"hosts"`
+	Steps             []CustomStep `json:"steps"`
+	// Azure overrides settings of the generated azure.json, using its field names
+	Azure json.RawMessage `json:"azure,omitempty"`
+	// Oracle overrides settings of the generated OCI cloud-provider.yaml
+	Oracle *OracleConfig `json:"oracle,omitempty"`
+	// BareMetal configures the nodes of the none provider
+	BareMetal *BareMetalConfig `json:"baremetal,omitempty"`
+}
+
+// OracleConfig holds the network settings the OCI cloud controller manager is
+// configured with. The VCN and load balancer subnets are required; the compartment
+// defaults to the first control plane's.
+type OracleConfig struct {
+	Compartment string `json:"compartment"`
+	VCN         string `json:"vcn"`
+	// LoadBalancerSubnets are one regional or two availability domain subnets
+	LoadBalancerSubnets []string `json:"loadBalancerSubnets"`
+	// SecurityListManagementMode is All, Frontend or None
+	SecurityListManagementMode string `json:"securityListManagementMode"`
+}
+
+// Validate checks that the settings the instance metadata cannot provide are given.
+// A nil configuration is missing all of them.
+func (c *OracleConfig) Validate() error {
+	var missing []string
+	if c == nil || c.VCN == "" {
+		missing = append(missing, "oracle.vcn")
+	}
+	if c == nil || len(c.LoadBalancerSubnets) == 0 {
+		missing = append(missing, "oracle.loadBalancerSubnets")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the oracle provider requires %s in the config file: the instance metadata does not name the VCN and subnets",
+			strings.Join(missing, " and "))
+	}
+	if len(c.LoadBalancerSubnets) > 2 {
+		return fmt.Errorf("oracle.loadBalancerSubnets takes at most two subnets")
+	}
+	return nil
+}
+
+// BareMetalConfig configures hosts without a cloud provider
+type BareMetalConfig struct {
+	// Interface is the network interface whose address the nodes register with and
+	// whose reverse DNS name they are named after; it defaults to the interface of
+	// the default route
+	Interface string `json:"interface"`
+	// MetalLB installs MetalLB to provide Services of type LoadBalancer when set
+	MetalLB *MetalLBConfig `json:"metallb"`
+}
+
+// MetalLBConfig is the layer 2 address pool MetalLB assigns load balancer addresses from
+type MetalLBConfig struct {
+	// Addresses are CIDRs or "first-last" ranges on the nodes' network
+	Addresses []string `json:"addresses"`
+	// Version is the MetalLB release, such as "v0.14.9"
+	Version string `json:"version"`
+}
+
+// SSHConfig holds the SSH settings shared by all hosts
+type SSHConfig struct {
+	User       string `json:"user"`
+	Port       string `json:"port"`
+	PrivateKey string `json:"key"`
+	Password   string `json:"password"`
+	// JumpHost is a comma-separated chain of "[user@]host[:port]" bastions, like ssh -J
+	JumpHost string `json:"jumpHost"`
+}
+
+// HostConfig describes one node of the cluster
+type HostConfig struct {
+	Address string `json:"address"`
+	// Role is "control-plane" or "worker"; the first host defaults to control-plane
+	Role string `json:"role"`
+	// User and Port override the shared SSH settings
+	User     string `json:"user"`
+	Port     string `json:"port"`
+	JumpHost string `json:"jumpHost"`
+}
+
+// CustomStep is a user-defined installation step placed before or after a built-in one
+type CustomStep struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Run is a shell snippet executed on the host
+	Run string `json:"run"`
+	// Plugin is the path of a Go plugin exporting a Run function
+	Plugin string `json:"plugin"`
+	// Before or After name the step this one is placed next to
+	Before string `json:"before"`
+	After  string `json:"after"`
+	// Roles limits the step to hosts with one of the roles
+	Roles []string `json:"roles"`
+	// Check is a shell probe that exits 0 when the step is already done
+	Check string `json:"check"`
+	// Retries and RetryDelay (a Go duration such as "10s") control retries
+	Retries    int    `json:"retries"`
+	RetryDelay string `json:"retryDelay"`
+}
+
+// LoadFile reads and validates a JSON configuration file
+func LoadFile(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var fc FileConfig
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	// Expand ~ since the shell does not see paths inside the file
+	if strings.HasPrefix(fc.SSH.PrivateKey, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			fc.SSH.PrivateKey = filepath.Join(home, fc.SSH.PrivateKey[2:])
+		}
+	}
+
+	for n, step := range fc.Steps {
+		if step.Name == "" {
+			return nil, fmt.Errorf("config file %s: step %d has no name", path, n+1)
+		}
+		if (step.Run == "") == (step.Plugin == "") {
+			return nil, fmt.Errorf("config file %s: step '%s' needs exactly one of run or plugin", path, step.Name)
+		}
+		if step.Before != "" && step.After != "" {
+			return nil, fmt.Errorf("config file %s: step '%s' cannot set both before and after", path, step.Name)
+		}
+	}
+
+	return &fc, nil
+}
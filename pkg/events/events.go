@@ -0,0 +1,111 @@
+// Package events carries installation progress as structured events
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of event
+type Type string
+
+const (
+	StepStarted  Type = "step-started"
+	StepFinished Type = "step-finished"
+	StepSkipped  Type = "step-skipped"
+	StepFailed   Type = "step-failed"
+	// CommandExecuted is emitted before a remote command runs
+	CommandExecuted Type = "command"
+	// FileWritten is emitted before a remote file is written
+	FileWritten Type = "file"
+	// MetadataDiscovered carries the instance metadata read from the cloud provider
+	MetadataDiscovered Type = "metadata"
+	Warning            Type = "warning"
+	Info               Type = "info"
+)
+
+// Event is a single progress notification
+type Event struct {
+	Type    Type      `json:"type"`
+	Time    time.Time `json:"time"`
+	Host    string    `json:"host,omitempty"`
+	Step    string    `json:"step,omitempty"`
+	Message string    `json:"message,omitempty"`
+	// Command is the remote command, or the remote path for FileWritten
+	Command  string            `json:"command,omitempty"`
+	Data     map[string]string `json:"data,omitempty"`
+	Duration time.Duration     `json:"duration,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// Handler receives events
+type Handler func(Event)
+
+// Logger emits events to its handlers. The zero value and a nil Logger discard events.
+type Logger struct {
+	mu       sync.Mutex
+	handlers []Handler
+	host     string
+	parent   *Logger
+}
+
+// NewLogger creates a logger that emits to the handlers
+func NewLogger(handlers ...Handler) *Logger {
+	return &Logger{handlers: handlers}
+}
+
+// AddHandler registers another handler
+func (l *Logger) AddHandler(h Handler) {
+	l.root().mu.Lock()
+	defer l.root().mu.Unlock()
+	l.root().handlers = append(l.root().handlers, h)
+}
+
+// ForHost returns a logger that tags every event with the host
+func (l *Logger) ForHost(host string) *Logger {
+	return &Logger{host: host, parent: l.root()}
+}
+
+// Emit sends an event to every handler, filling in the time and host
+func (l *Logger) Emit(e Event) {
+	if l == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Host == "" {
+		e.Host = l.host
+	}
+
+	root := l.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	for _, h := range root.handlers {
+		h(e)
+	}
+}
+
+// Command emits a CommandExecuted event
+func (l *Logger) Command(command string) {
+	l.Emit(Event{Type: CommandExecuted, Command: command})
+}
+
+// Infof emits an informational message
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.Emit(Event{Type: Info, Message: fmt.Sprintf(format, args...)})
+}
+
+// Warnf emits a warning
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.Emit(Event{Type: Warning, Message: fmt.Sprintf(format, args...)})
+}
+
+// root returns the logger holding the handlers
+func (l *Logger) root() *Logger {
+	if l.parent != nil {
+		return l.parent
+	}
+	return l
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Output formats accepted by NewRenderer
+const (
+	FormatHuman = "human"
+	FormatJSON  = "json"
+	FormatQuiet = "quiet"
+)
+
+// NewRenderer returns the handler for an output format
+func NewRenderer(format string, w io.Writer) (Handler, error) {
+	switch format {
+	case FormatHuman, "":
+		return HumanHandler(w), nil
+	case FormatJSON:
+		return JSONHandler(w), nil
+	case FormatQuiet:
+		return QuietHandler(w), nil
+	default:
+		return nil, fmt.Errorf("invalid output format '%s': use %s, %s, or %s", format, FormatHuman, FormatJSON, FormatQuiet)
+	}
+}
+
+// HumanHandler renders events as the installer's human-readable progress lines
+func HumanHandler(w io.Writer) Handler {
+	return func(e Event) {
+		switch e.Type {
+		case StepStarted:
+			fmt.Fprintf(w, "\n[*] %s on %s...\n", e.Message, e.Host)
+		case StepFinished:
+			fmt.Fprintf(w, "[✓] %s completed successfully on %s (%s)\n", e.Message, e.Host, e.Duration.Round(time.Second))
+		case StepSkipped:
+			fmt.Fprintf(w, "\n[-] %s on %s, skipping\n", e.Message, e.Host)
+		case StepFailed:
+			fmt.Fprintf(w, "[x] %s failed on %s: %s\n", e.Message, e.Host, e.Error)
+		case CommandExecuted:
+			fmt.Fprintf(w, "  Running: %s\n", e.Command)
+		case FileWritten:
+			fmt.Fprintf(w, "  Writing: %s\n", e.Command)
+		case MetadataDiscovered:
+			// Only of interest to machine consumers
+		case Warning:
+			fmt.Fprintf(w, "Warning: %s\n", e.Message)
+		default:
+			fmt.Fprintln(w, e.Message)
+		}
+	}
+}
+
+// JSONHandler writes every event as one JSON object per line
+func JSONHandler(w io.Writer) Handler {
+	encoder := json.NewEncoder(w)
+	return func(e Event) {
+		encoder.Encode(e)
+	}
+}
+
+// QuietHandler prints only warnings and failed steps
+func QuietHandler(w io.Writer) Handler {
+	human := HumanHandler(w)
+	return func(e Event) {
+		if e.Type == Warning || e.Type == StepFailed {
+			human(e)
+		}
+	}
+}
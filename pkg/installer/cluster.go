@@ -0,0 +1,120 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// APIServerPort is the port kubeadm binds the API server to
+const APIServerPort = "6443"
+
+// Cluster is shared by the installers of one engine run. It knows which host
+// initializes the cluster and hands out the credentials other nodes join with.
+type Cluster struct {
+	// ControlPlane is the host that initializes the cluster, or the existing
+	// control plane when nodes join a running cluster
+	ControlPlane *Installer
+	// Endpoint is the stable API server address; it is required for clusters with
+	// more than one control plane
+	Endpoint string
+
+	mu             sync.Mutex
+	joinCommand    string
+	certificateKey string
+}
+
+// IsPrimary returns true if the installer is the cluster's initializing control plane
+func (c *Cluster) IsPrimary(i *Installer) bool {
+	return c == nil || c.ControlPlane == i
+}
+
+// SetJoinCommand records the join command printed by kubeadm
+func (c *Cluster) SetJoinCommand(command string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.joinCommand = command
+}
+
+// JoinCommand returns the worker join command, creating a token on the control plane
+// the first time it is needed
+func (c *Cluster) JoinCommand() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.joinCommand != "" {
+		return c.joinCommand, nil
+	}
+	if c.ControlPlane == nil {
+		return "", fmt.Errorf("no control plane to join")
+	}
+
+	output, err := c.ControlPlane.Client.RunCommand("sudo kubeadm token create --print-join-command")
+	if err != nil {
+		return "", fmt.Errorf("failed to create join command on %s: %v", c.ControlPlane.Config.Host, err)
+	}
+	c.joinCommand = strings.TrimSpace(output)
+	return c.joinCommand, nil
+}
+
+// CertificateKey uploads the control plane certificates and returns the key
+// additional control planes decrypt them with. The upload expires after two hours.
+func (c *Cluster) CertificateKey() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.certificateKey != "" {
+		return c.certificateKey, nil
+	}
+	if c.ControlPlane == nil {
+		return "", fmt.Errorf("no control plane to join")
+	}
+
+	output, err := c.ControlPlane.Client.RunCommand("sudo kubeadm init phase upload-certs --upload-certs 2>/dev/null | tail -n 1")
+	if err != nil {
+		return "", fmt.Errorf("failed to upload certificates on %s: %v", c.ControlPlane.Config.Host, err)
+	}
+	c.certificateKey = strings.TrimSpace(output)
+	return c.certificateKey, nil
+}
+
+// JoinCluster joins the host to the cluster as a worker, or as an additional control
+// plane for control-plane hosts
+func (i *Installer) JoinCluster() error {
+	joinCmd, err := i.Cluster.JoinCommand()
+	if err != nil {
+		return err
+	}
+
+	certificateKey := ""
+	if i.Role == RoleControlPlane {
+		if certificateKey, err = i.Cluster.CertificateKey(); err != nil {
+			return err
+		}
+	}
+
+	// A configuration file carries the kubelet's cloud provider arguments to the node
+	kc, err := i.BuildKubeadmConfig()
+	if err != nil {
+		return err
+	}
+	joinConfig, err := kc.RenderJoin(joinCmd, certificateKey)
+	if err != nil {
+		return err
+	}
+	if err := i.Client.WriteFile(kubeadmJoinConfigPath, []byte(joinConfig), 0600); err != nil {
+		return err
+	}
+
+	commands := []string{"sudo kubeadm join --config " + kubeadmJoinConfigPath}
+	if i.Role == RoleControlPlane {
+		// Configure kubectl like on the first control plane
+		commands = append(commands,
+			"mkdir -p $HOME/.kube",
+			"sudo cp -f /etc/kubernetes/admin.conf $HOME/.kube/config",
+			"sudo chown $(id -u):$(id -g) $HOME/.kube/config",
+		)
+	}
+
+	return i.Client.RunCommands(commands)
+}
@@ -0,0 +1,101 @@
+package installer
+
+import (
+	"fmt"
+	"plugin"
+	"time"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+)
+
+// PluginRunFunc is the signature of the Run symbol exported by step plugins
+type PluginRunFunc = func(client ssh.Executor, cfg *config.Config) error
+
+// RegisterCustomSteps adds user-defined steps from the configuration file to the registry.
+// Steps without a before or after position run at the end.
+func RegisterCustomSteps(r *Registry, defs []config.CustomStep) error {
+	for _, def := range defs {
+		step, err := newCustomStep(def)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case def.Before != "":
+			err = r.InsertBefore(def.Before, step)
+		case def.After != "":
+			err = r.InsertAfter(def.After, step)
+		default:
+			if names := r.Names(); len(names) > 0 {
+				step.DependsOn = append(step.DependsOn, names[len(names)-1])
+			}
+			r.Register(step)
+		}
+		if err != nil {
+			return fmt.Errorf("step '%s': %v", def.Name, err)
+		}
+	}
+	return nil
+}
+
+// newCustomStep builds a step running a shell snippet or a Go plugin
+func newCustomStep(def config.CustomStep) (*Step, error) {
+	step := &Step{
+		Name:        def.Name,
+		Description: def.Description,
+		Roles:       def.Roles,
+		Retry:       RetryPolicy{Attempts: def.Retries + 1},
+	}
+	if step.Description == "" {
+		step.Description = "Running custom step " + def.Name
+	}
+
+	if def.RetryDelay != "" {
+		delay, err := time.ParseDuration(def.RetryDelay)
+		if err != nil {
+			return nil, fmt.Errorf("step '%s': invalid retryDelay: %v", def.Name, err)
+		}
+		step.Retry.Delay = delay
+	}
+
+	if def.Check != "" {
+		step.Check = probe(def.Check)
+	}
+
+	if def.Run != "" {
+		snippet := def.Run
+		step.Run = func(i *Installer) error {
+			return i.Client.RunCommands([]string{snippet})
+		}
+		return step, nil
+	}
+
+	run, err := loadPlugin(def.Plugin)
+	if err != nil {
+		return nil, fmt.Errorf("step '%s': %v", def.Name, err)
+	}
+	step.Run = func(i *Installer) error {
+		return run(i.Client, i.Config)
+	}
+	return step, nil
+}
+
+// loadPlugin opens a Go plugin and looks up its Run function
+func loadPlugin(path string) (PluginRunFunc, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plugin %s: %v", path, err)
+	}
+
+	symbol, err := p.Lookup("Run")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s does not export Run: %v", path, err)
+	}
+
+	run, ok := symbol.(PluginRunFunc)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s: Run must be a func(ssh.Executor, *config.Config) error", path)
+	}
+	return run, nil
+}
@@ -0,0 +1,96 @@
+package installer
+
+import (
+	"fmt"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/providers"
+)
+
+// DetectPlatform reads /etc/os-release and the machine architecture from the remote host
+// and records the distribution, version and architecture in the configuration. An
+// explicit distribution from the command line takes precedence over the detected one.
+// The cloud provider is detected as well.
+func (i *Installer) DetectPlatform() error {
+	osRelease, err := i.Client.RunCommand("cat /etc/os-release")
+	if err != nil {
+		return fmt.Errorf("failed to read /etc/os-release: %v", err)
+	}
+
+	machine, err := i.Client.RunCommand("uname -m")
+	if err != nil {
+		return fmt.Errorf("failed to detect architecture: %v", err)
+	}
+
+	arch, err := config.ParseArchitecture(machine)
+	if err != nil {
+		return err
+	}
+
+	release := config.ParseOSRelease(osRelease)
+	detected, exact, err := release.Distribution()
+	if err != nil && i.Config.Distribution == "" {
+		return err
+	}
+
+	// Derivatives matched through ID_LIKE have their own version numbering
+	version := release.VersionID
+	if !exact {
+		version = ""
+	}
+
+	distro := detected
+	if i.Config.Distribution != "" {
+		if detected != "" && detected != i.Config.Distribution {
+			i.Log.Warnf("Detected distribution '%s' but '%s' was requested; using '%s'",
+				detected, i.Config.Distribution, i.Config.Distribution)
+			version = ""
+		}
+		distro = i.Config.Distribution
+	}
+
+	if err := config.ValidatePlatform(distro, version, arch); err != nil {
+		return err
+	}
+
+	i.Config.Distribution = distro
+	// Derivatives and overrides keep no version, so that releases are not judged by
+	// another distribution's numbering
+	i.Config.DistributionVersion = version
+	i.Config.Arch = arch
+
+	return i.DetectProvider()
+}
+
+// DetectProvider identifies the cloud the host runs on. The auto provider is replaced
+// by the detected one, or by none when no cloud is recognized; an explicit provider
+// takes precedence, with a warning when the host's DMI data names another cloud.
+func (i *Installer) DetectProvider() error {
+	if i.Config.Provider != config.Auto {
+		detected := providers.DetectFromDMI(i.Client)
+		if detected != "" && detected != i.Config.Provider {
+			i.Log.Warnf("Detected cloud provider '%s' but '%s' was requested; using '%s'",
+				detected, i.Config.Provider, i.Config.Provider)
+		}
+		return nil
+	}
+
+	detected := providers.Detect(i.Client)
+	if detected == "" {
+		// Bare metal and private clouds have no cloud integration
+		i.Log.Warnf("Could not detect a cloud provider; installing without cloud integration (provider none). Set -provider to choose one")
+		detected = config.None
+	} else {
+		i.Log.Infof("Detected cloud provider: %s", detected)
+	}
+	if detected == config.Oracle {
+		if err := i.Config.Oracle.Validate(); err != nil {
+			return err
+		}
+	}
+
+	i.Config.Provider = detected
+	i.Provider = providers.NewProvider(i.Client, i.Config)
+	i.SetLogger(i.Log)
+	return nil
+}
@@ -0,0 +1,303 @@
+package installer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
+)
+
+// Host is a node the engine runs steps on
+type Host struct {
+	// Role selects which steps run on the host
+	Role string
+	// Installer holds the host's connection and configuration
+	Installer *Installer
+}
+
+// Name returns the address of the host
+func (h *Host) Name() string {
+	return h.Installer.Config.Host
+}
+
+// RunOptions controls which steps are run
+type RunOptions struct {
+	// FromStep skips every step before the named one and forces the rest to run
+	FromStep string
+	// OnlyStep runs just the named step, even if it was completed before
+	OnlyStep string
+}
+
+// StepResult records the outcome of one step on one host
+type StepResult struct {
+	Step     string
+	Host     string
+	Skipped  bool
+	Attempts int
+	Duration time.Duration
+	Err      error
+}
+
+// Hooks are called around every step on every host
+type Hooks struct {
+	BeforeStep func(step *Step, host *Host)
+	AfterStep  func(step *Step, host *Host, result StepResult)
+}
+
+// Engine runs registered steps across hosts in dependency order
+type Engine struct {
+	Registry *Registry
+	Hosts    []*Host
+	Hooks    Hooks
+	// Parallel runs a step on all of its hosts at once instead of one after another
+	Parallel bool
+	// Cluster is shared by every host; the first control-plane host initializes it
+	Cluster *Cluster
+}
+
+// NewEngine creates an engine for the registry
+func NewEngine(registry *Registry) *Engine {
+	return &Engine{Registry: registry, Cluster: &Cluster{}}
+}
+
+// AddHost adds a host with a role
+func (e *Engine) AddHost(role string, i *Installer) {
+	i.Role = role
+	i.Cluster = e.Cluster
+	if role == RoleControlPlane && e.Cluster.ControlPlane == nil {
+		e.Cluster.ControlPlane = i
+	}
+	e.Hosts = append(e.Hosts, &Host{Role: role, Installer: i})
+}
+
+// Run executes the steps on every host, skipping those already completed and
+// recording progress in each host's state. It stops after the first step that fails
+// on any host, or when the context is cancelled between steps, and returns the
+// results collected so far.
+func (e *Engine) Run(ctx context.Context, opts RunOptions) ([]StepResult, error) {
+	steps, err := e.Registry.Ordered()
+	if err != nil {
+		return nil, err
+	}
+
+	selected, err := selectSteps(steps, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[*Host]*State)
+	for _, host := range e.Hosts {
+		state, err := host.Installer.LoadState()
+		if err == nil {
+			err = state.checkVersion(host.Installer.Config.KubernetesVersion)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", host.Name(), err)
+		}
+		states[host] = state
+	}
+
+	forced := opts.FromStep != "" || opts.OnlyStep != ""
+
+	var results []StepResult
+	for _, step := range steps {
+		if !selected[step.Name] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		var targets []*Host
+		for _, host := range e.Hosts {
+			if step.appliesTo(host.Role) {
+				targets = append(targets, host)
+			}
+		}
+
+		stepResults := make([]StepResult, len(targets))
+		run := func(n int, host *Host) {
+			stepResults[n] = e.runOnHost(ctx, step, host, states[host], forced)
+		}
+
+		if e.Parallel {
+			var wg sync.WaitGroup
+			for n, host := range targets {
+				wg.Add(1)
+				go func(n int, host *Host) {
+					defer wg.Done()
+					run(n, host)
+				}(n, host)
+			}
+			wg.Wait()
+		} else {
+			for n, host := range targets {
+				run(n, host)
+				if stepResults[n].Err != nil {
+					stepResults = stepResults[:n+1]
+					break
+				}
+			}
+		}
+
+		results = append(results, stepResults...)
+		for _, result := range stepResults {
+			if result.Err != nil {
+				return results, fmt.Errorf("failed to %s on %s: %v", strings.ToLower(step.Description), result.Host, result.Err)
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// runOnHost runs a step with its hooks, probes and retries on one host
+func (e *Engine) runOnHost(ctx context.Context, step *Step, host *Host, state *State, forced bool) StepResult {
+	start := time.Now()
+	result := StepResult{Step: step.Name, Host: host.Name()}
+	i := host.Installer
+
+	if e.Hooks.BeforeStep != nil {
+		e.Hooks.BeforeStep(step, host)
+	}
+	defer func() {
+		result.Duration = time.Since(start)
+		if result.Err != nil {
+			i.Log.Emit(events.Event{Type: events.StepFailed, Step: step.Name, Message: step.Description,
+				Duration: result.Duration, Error: result.Err.Error()})
+		}
+		if e.Hooks.AfterStep != nil {
+			e.Hooks.AfterStep(step, host, result)
+		}
+	}()
+
+	if step.Skip != nil {
+		skip, err := step.Skip(i)
+		if err != nil {
+			result.Err = fmt.Errorf("failed to check step %s: %v", step.Name, err)
+			return result
+		}
+		if skip {
+			i.Log.Emit(events.Event{Type: events.StepSkipped, Step: step.Name, Message: step.Description + " does not apply"})
+			result.Skipped = true
+			return result
+		}
+	}
+
+	if !forced {
+		done := state.IsCompleted(step.Name)
+		if step.Check != nil {
+			var err error
+			if done, err = step.Check(i); err != nil {
+				result.Err = fmt.Errorf("failed to check step %s: %v", step.Name, err)
+				return result
+			}
+		}
+		if done {
+			i.Log.Emit(events.Event{Type: events.StepSkipped, Step: step.Name, Message: step.Description + " already done"})
+			result.Skipped = true
+			if !state.IsCompleted(step.Name) {
+				state.MarkCompleted(step.Name)
+				result.Err = i.SaveState(state)
+			}
+			return result
+		}
+	}
+
+	i.Log.Emit(events.Event{Type: events.StepStarted, Step: step.Name, Message: step.Description})
+
+	if err := runStepHooks(step.Pre, i); err != nil {
+		result.Err = fmt.Errorf("pre hook failed: %v", err)
+		return result
+	}
+
+	delay := step.Retry.Delay
+	for {
+		result.Attempts++
+		result.Err = step.Run(i)
+		if result.Err == nil || result.Attempts >= step.Retry.Attempts {
+			break
+		}
+		i.Log.Warnf("%s failed on %s (attempt %d of %d), retrying in %s: %v",
+			step.Description, host.Name(), result.Attempts, step.Retry.Attempts, delay, result.Err)
+		select {
+		case <-ctx.Done():
+			result.Err = ctx.Err()
+			return result
+		case <-time.After(delay):
+		}
+		if step.Retry.Backoff > 1 {
+			delay = time.Duration(float64(delay) * step.Retry.Backoff)
+		}
+	}
+	if result.Err != nil {
+		return result
+	}
+
+	if err := runStepHooks(step.Post, i); err != nil {
+		result.Err = fmt.Errorf("post hook failed: %v", err)
+		return result
+	}
+
+	i.Log.Emit(events.Event{Type: events.StepFinished, Step: step.Name, Message: step.Description, Duration: time.Since(start)})
+
+	state.MarkCompleted(step.Name)
+	result.Err = i.SaveState(state)
+	return result
+}
+
+// runStepHooks runs pre or post hooks in order, stopping at the first error
+func runStepHooks(hooks []func(i *Installer) error, i *Installer) error {
+	for _, hook := range hooks {
+		if err := hook(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectSteps returns the set of steps to run for the options
+func selectSteps(steps []*Step, opts RunOptions) (map[string]bool, error) {
+	if opts.FromStep != "" && opts.OnlyStep != "" {
+		return nil, fmt.Errorf("--from-step and --only-step cannot be used together")
+	}
+
+	selected := make(map[string]bool)
+	started := opts.FromStep == ""
+	found := opts.FromStep == "" && opts.OnlyStep == ""
+
+	for _, step := range steps {
+		switch {
+		case opts.OnlyStep != "":
+			if step.Name == opts.OnlyStep {
+				selected[step.Name] = true
+				found = true
+			}
+		default:
+			if step.Name == opts.FromStep {
+				started = true
+				found = true
+			}
+			if started {
+				selected[step.Name] = true
+			}
+		}
+	}
+
+	if !found {
+		name := opts.OnlyStep
+		if name == "" {
+			name = opts.FromStep
+		}
+		var names []string
+		for _, step := range steps {
+			names = append(names, step.Name)
+		}
+		return nil, fmt.Errorf("unknown step '%s': use one of %s", name, strings.Join(names, ", "))
+	}
+
+	return selected, nil
+}
@@ -2,35 +2,61 @@
 package installer
 
 import (
-	"fmt"
+	"os"
+	"strings"
 
 	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
 	"github.com/ochestra-tech/kubeforge-cli/pkg/providers"
 	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
 )
 
 // Installer manages the Kubernetes installation process
 type Installer struct {
-	Client   *ssh.Client
+	Client   ssh.Executor
 	Config   *config.Config
 	Provider providers.Provider
+	// Log receives step progress, commands and warnings
+	Log *events.Logger
+	// Role is the node role the host is installed with
+	Role string
+	// Cluster is shared with the other hosts of the installation
+	Cluster *Cluster
+	// JoinCommand is set once the cluster is initialized on a control-plane host
+	JoinCommand string
 }
 
-// NewInstaller creates a new installer
-func NewInstaller(client *ssh.Client, cfg *config.Config) *Installer {
-	provider := providers.NewProvider(client, cfg)
+// loggerSetter is implemented by executors and providers that report progress
+type loggerSetter interface {
+	SetLogger(l *events.Logger)
+}
 
-	return &Installer{
+// NewInstaller creates a new installer that prints its progress to stdout
+func NewInstaller(client ssh.Executor, cfg *config.Config) *Installer {
+	i := &Installer{
 		Client:   client,
 		Config:   cfg,
-		Provider: provider,
+		Provider: providers.NewProvider(client, cfg),
+		Role:     RoleControlPlane,
+	}
+	i.Cluster = &Cluster{ControlPlane: i}
+	i.SetLogger(events.NewLogger(events.HumanHandler(os.Stdout)).ForHost(cfg.Host))
+	return i
+}
+
+// SetLogger sets where the installer, its executor and its provider report progress
+func (i *Installer) SetLogger(l *events.Logger) {
+	i.Log = l
+	if setter, ok := i.Client.(loggerSetter); ok {
+		setter.SetLogger(l)
+	}
+	if setter, ok := i.Provider.(loggerSetter); ok {
+		setter.SetLogger(l)
 	}
 }
 
 // InstallPrerequisites installs required dependencies based on cloud provider and distribution
 func (i *Installer) InstallPrerequisites() error {
-	pm := i.Config.GetPackageManager()
-
 	// Common prerequisites for all distributions
 	commonCommands := []string{
 		"sudo swapoff -a",
@@ -40,54 +66,36 @@ func (i *Installer) InstallPrerequisites() error {
 		"echo '1' | sudo tee /proc/sys/net/ipv4/ip_forward",
 		"echo '1' | sudo tee /proc/sys/net/bridge/bridge-nf-call-iptables",
 		"echo '1' | sudo tee /proc/sys/net/bridge/bridge-nf-call-ip6tables",
-		"cat <<EOF | sudo tee /etc/modules-load.d/k8s.conf\noverlay\nbr_netfilter\nEOF",
-		"cat <<EOF | sudo tee /etc/sysctl.d/k8s.conf\nnet.bridge.bridge-nf-call-iptables = 1\nnet.bridge.bridge-nf-call-ip6tables = 1\nnet.ipv4.ip_forward = 1\nEOF",
 		"sudo sysctl --system",
 	}
 
-	// Distribution-specific commands
-	var distroCommands []string
-
-	if i.Config.IsDebianBased() {
-		distroCommands = []string{
-			pm["update"],
-			pm["install"] + " apt-transport-https ca-certificates curl software-properties-common gnupg lsb-release",
-		}
-	} else if i.Config.IsRHELBased() {
-		distroCommands = []string{
-			"sudo setenforce 0 || true",
-			"sudo sed -i 's/^SELINUX=enforcing$/SELINUX=permissive/' /etc/selinux/config || true",
-			pm["update"],
-			pm["install"] + " curl wget socat conntrack ebtables ipset",
+	// Persist kernel modules and sysctl settings across reboots
+	persistentFiles := []struct {
+		path    string
+		content string
+	}{
+		{"/etc/modules-load.d/k8s.conf", "overlay\nbr_netfilter\n"},
+		{"/etc/sysctl.d/k8s.conf", "net.bridge.bridge-nf-call-iptables = 1\nnet.bridge.bridge-nf-call-ip6tables = 1\nnet.ipv4.ip_forward = 1\n"},
+	}
+	for _, file := range persistentFiles {
+		if err := i.Client.WriteFile(file.path, []byte(file.content), 0644); err != nil {
+			return err
 		}
 	}
 
-	// Cloud provider-specific commands
-	var providerCommands []string
+	// Distribution-specific commands
+	distroCommands, err := i.distroPrerequisites()
+	if err != nil {
+		return err
+	}
 
-	switch i.Config.Provider {
-	case config.AWS:
-		// AWS-specific optimizations
-		providerCommands = []string{
-			"sudo hostnamectl set-hostname $(curl -s http://169.254.169.254/latest/meta-data/local-hostname) || true",
-		}
-	case config.GCP:
-		// GCP-specific optimizations
-		providerCommands = []string{
-			"sudo hostnamectl set-hostname $(curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/instance/hostname | cut -d. -f1) || true",
-		}
-	case config.Azure:
-		// Azure-specific optimizations
-		providerCommands = []string{
-			"sudo hostnamectl set-hostname $(curl -s -H Metadata:true 'http://169.254.169.254/metadata/instance/compute/name?api-version=2019-06-01&format=text') || true",
-		}
-	case config.Oracle:
-		// Oracle-specific optimizations
-		providerCommands = []string{
-			// Oracle Cloud doesn't have a standard metadata service like other providers
-			// so we'll just use the hostname command
-			"sudo hostnamectl set-hostname $(hostname) || true",
-		}
+	// Name the node as the cloud controller manager expects, from the instance metadata
+	var providerCommands []string
+	metadata, err := i.Provider.GetMetadata()
+	if err != nil {
+		i.Log.Warnf("Could not read the instance metadata: %v", err)
+	} else if metadata.Hostname != "" {
+		providerCommands = append(providerCommands, "sudo hostnamectl set-hostname "+metadata.Hostname)
 	}
 
 	// Combine all commands
@@ -97,26 +105,82 @@ func (i *Installer) InstallPrerequisites() error {
 	return i.Client.RunCommands(commands)
 }
 
+// distroPrerequisites returns the commands installing the packages the distribution
+// lacks. Immutable distributions ship everything needed.
+func (i *Installer) distroPrerequisites() ([]string, error) {
+	if i.Config.IsImmutable() {
+		return nil, nil
+	}
+	pm, err := i.Config.GetPackageManager()
+	if err != nil {
+		return nil, err
+	}
+
+	var distroCommands []string
+	switch {
+	case i.Config.IsDebianBased():
+		distroCommands = []string{
+			pm.Update(),
+			pm.Install("apt-transport-https", "ca-certificates", "curl", "software-properties-common", "gnupg", "lsb-release"),
+		}
+	case i.Config.IsSUSEBased():
+		distroCommands = []string{
+			pm.Update(),
+			pm.Install("curl", "socat", "conntrack-tools", "ebtables", "iptables", "ipset", "tar"),
+		}
+	case i.Config.IsRHELBased() && i.Config.UsesDNF():
+		distroCommands = []string{
+			pm.Update(),
+			// curl-minimal is preinstalled and conflicts with curl on newer releases
+			pm.Install("socat", "conntrack-tools", "iptables", "ipset", "tar"),
+		}
+	case i.Config.IsRHELBased():
+		distroCommands = []string{
+			pm.Update(),
+			pm.Install("curl", "wget", "socat", "conntrack", "ebtables", "ipset"),
+		}
+	}
+
+	// Keep SELinux enforcing and rely on container-selinux policies instead of disabling it
+	if i.Config.IsRHELBased() {
+		distroCommands = append(distroCommands,
+			"if selinuxenabled; then "+pm.Install("container-selinux", "policycoreutils")+"; fi",
+			"sudo mkdir -p /var/lib/etcd /etc/kubernetes/manifests",
+			"if selinuxenabled; then sudo chcon -R -t container_file_t /var/lib/etcd; fi",
+		)
+	}
+	return distroCommands, nil
+}
+
 // InstallContainerRuntime installs and configures containerd based on distribution
 func (i *Installer) InstallContainerRuntime() error {
-	pm := i.Config.GetPackageManager()
+	// Immutable distributions ship containerd as part of the OS image
+	if i.Config.IsImmutable() {
+		return i.configureSystemContainerd()
+	}
+
+	pm, err := i.Config.GetPackageManager()
+	if err != nil {
+		return err
+	}
 
 	var commands []string
 
-	if i.Config.IsDebianBased() {
+	if repo := i.Config.ContainerdRepository(); repo != nil {
+		commands = append(commands, pm.AddRepository(*repo)...)
+		commands = append(commands, pm.Update(), pm.Install("containerd.io"))
+	} else if i.Config.IsSUSEBased() {
+		// SLES provides containerd through the Containers Module
 		commands = []string{
-			// Install containerd
-			"sudo mkdir -p /etc/apt/keyrings",
-			"curl -fsSL https://download.docker.com/linux/" + i.Config.Distribution + "/gpg | sudo gpg --dearmor -o /etc/apt/keyrings/docker.gpg",
-			"echo \"deb [arch=$(dpkg --print-architecture) signed-by=/etc/apt/keyrings/docker.gpg] https://download.docker.com/linux/" + i.Config.Distribution + " $(lsb_release -cs) stable\" | sudo tee /etc/apt/sources.list.d/docker.list > /dev/null",
-			pm["update"],
-			pm["install"] + " containerd.io",
+			"if command -v SUSEConnect > /dev/null; then . /etc/os-release && sudo SUSEConnect -p sle-module-containers/$VERSION_ID/$(uname -m) || true; fi",
+			pm.Update(),
+			pm.Install("containerd"),
 		}
-	} else if i.Config.IsRHELBased() {
+	} else {
+		// Amazon Linux ships containerd in its own repositories
 		commands = []string{
-			pm["install"] + " yum-utils device-mapper-persistent-data lvm2",
-			pm["repository"] + " https://download.docker.com/linux/centos/docker-ce.repo",
-			pm["install"] + " containerd.io",
+			"if command -v amazon-linux-extras > /dev/null; then sudo amazon-linux-extras enable docker; fi",
+			pm.Install("containerd"),
 		}
 	}
 
@@ -125,6 +189,7 @@ func (i *Installer) InstallContainerRuntime() error {
 		"sudo mkdir -p /etc/containerd",
 		"sudo containerd config default | sudo tee /etc/containerd/config.toml",
 		"sudo sed -i 's/SystemdCgroup = false/SystemdCgroup = true/g' /etc/containerd/config.toml",
+		"if command -v selinuxenabled > /dev/null && selinuxenabled; then sudo sed -i 's/enable_selinux = false/enable_selinux = true/g' /etc/containerd/config.toml; fi",
 		"sudo systemctl restart containerd",
 		"sudo systemctl enable containerd",
 	}
@@ -136,24 +201,29 @@ func (i *Installer) InstallContainerRuntime() error {
 
 // InstallKubernetesComponents installs kubeadm, kubelet, and kubectl based on distribution
 func (i *Installer) InstallKubernetesComponents() error {
-	pm := i.Config.GetPackageManager()
+	// Immutable distributions get pinned binaries instead of packages
+	if i.Config.IsImmutable() {
+		if err := i.installKubernetesBinaries(); err != nil {
+			return err
+		}
+		return i.Client.RunCommands([]string{"sudo systemctl enable --now kubelet"})
+	}
+
+	pm, err := i.Config.GetPackageManager()
+	if err != nil {
+		return err
+	}
 
 	var commands []string
 
-	if i.Config.IsDebianBased() {
-		commands = []string{
-			"sudo curl -fsSLo /etc/apt/keyrings/kubernetes-archive-keyring.gpg https://packages.cloud.google.com/apt/doc/apt-key.gpg",
-			"echo \"deb [signed-by=/etc/apt/keyrings/kubernetes-archive-keyring.gpg] https://apt.kubernetes.io/ kubernetes-xenial main\" | sudo tee /etc/apt/sources.list.d/kubernetes.list",
-			pm["update"],
-			pm["install"] + " kubelet kubeadm kubectl",
-			"sudo apt-mark hold kubelet kubeadm kubectl",
-		}
-	} else if i.Config.IsRHELBased() {
-		commands = []string{
-			"cat <<EOF | sudo tee /etc/yum.repos.d/kubernetes.repo\n[kubernetes]\nname=Kubernetes\nbaseurl=https://packages.cloud.google.com/yum/repos/kubernetes-el7-\\$basearch\nenabled=1\ngpgcheck=1\nrepo_gpgcheck=1\ngpgkey=https://packages.cloud.google.com/yum/doc/yum-key.gpg https://packages.cloud.google.com/yum/doc/rpm-package-key.gpg\nEOF",
-			pm["install"] + " kubelet kubeadm kubectl --disableexcludes=kubernetes",
-		}
-	}
+	k8sPackages := []string{"kubelet", "kubeadm", "kubectl"}
+
+	commands = append(commands, pm.AddRepository(*i.Config.KubernetesRepository())...)
+	commands = append(commands,
+		pm.Update(),
+		pm.InstallPinned(i.Config.KubernetesVersion, k8sPackages...),
+		pm.Hold(k8sPackages...),
+	)
 
 	// Common configuration for all distributions
 	commonCommands := []string{
@@ -167,17 +237,24 @@ func (i *Installer) InstallKubernetesComponents() error {
 
 // InitializeCluster initializes the Kubernetes cluster with cloud provider-specific settings
 func (i *Installer) InitializeCluster() error {
-	// Get cloud provider-specific configuration
-	cloudConfig := i.Provider.GetCloudProviderOptions()
+	// Render the kubeadm configuration including cloud provider-specific settings
+	kc, err := i.BuildKubeadmConfig()
+	if err != nil {
+		return err
+	}
+	kubeadmConfig, err := kc.Render()
+	if err != nil {
+		return err
+	}
 
-	// Initialize Kubernetes cluster with cloud provider if specified
-	initCmd := "sudo kubeadm init --pod-network-cidr=10.244.0.0/16"
-	if cloudConfig != "" {
-		initCmd += " " + cloudConfig
+	if err := i.Client.WriteFile(kubeadmConfigPath, []byte(kubeadmConfig), 0600); err != nil {
+		return err
 	}
 
-	fmt.Printf("  Running: %s\n", initCmd)
-	_, err := i.Client.RunCommand(initCmd)
+	// Skip kubeadm init when resuming on an already initialized control plane
+	initCmd := "sudo test -f /etc/kubernetes/admin.conf || sudo kubeadm init --config " + kubeadmConfigPath
+	i.Log.Command(initCmd)
+	_, err = i.Client.RunCommand(initCmd)
 	if err != nil {
 		return err
 	}
@@ -185,12 +262,12 @@ func (i *Installer) InitializeCluster() error {
 	// Configure kubectl for the user
 	commands := []string{
 		"mkdir -p $HOME/.kube",
-		"sudo cp -i /etc/kubernetes/admin.conf $HOME/.kube/config",
+		"sudo cp -f /etc/kubernetes/admin.conf $HOME/.kube/config",
 		"sudo chown $(id -u):$(id -g) $HOME/.kube/config",
 		// Install Flannel CNI
 		"kubectl apply -f https://raw.githubusercontent.com/flannel-io/flannel/master/Documentation/kube-flannel.yml",
 		// Allow pods to run on the master node (optional, remove for production)
-		"kubectl taint nodes --all node-role.kubernetes.io/control-plane-",
+		"kubectl taint nodes --all node-role.kubernetes.io/control-plane- || true",
 	}
 
 	err = i.Client.RunCommands(commands)
@@ -201,9 +278,11 @@ func (i *Installer) InitializeCluster() error {
 	// Extract the join command for other nodes (if needed)
 	joinCmd, err := i.Client.RunCommand("sudo kubeadm token create --print-join-command")
 	if err != nil {
-		fmt.Printf("Warning: Could not create join command: %v\n", err)
+		i.Log.Warnf("Could not create join command: %v", err)
 	} else {
-		fmt.Printf("\nUse the following command to join other nodes to the cluster:\n%s\n", joinCmd)
+		i.JoinCommand = strings.TrimSpace(joinCmd)
+		i.Cluster.SetJoinCommand(i.JoinCommand)
+		i.Log.Infof("\nUse the following command to join other nodes to the cluster:\n%s", i.JoinCommand)
 	}
 
 	return nil
@@ -214,6 +293,17 @@ func (i *Installer) SetupCloudProviderIntegration() error {
 	return i.Provider.SetupCloudProvider()
 }
 
+// cloudProviderInstalled probes whether the cloud provider integration is deployed
+func (i *Installer) cloudProviderInstalled() (bool, error) {
+	return i.Provider.CloudProviderInstalled()
+}
+
+// externalCloudProvider returns true if a cloud controller manager deployed by the
+// provider initializes the nodes
+func (i *Installer) externalCloudProvider() bool {
+	return parseFlags(i.Provider.GetCloudProviderOptions())["cloud-provider"] == "external"
+}
+
 // DisplayCloudProviderInfo shows cloud provider-specific information
 func (i *Installer) DisplayCloudProviderInfo() {
 	i.Provider.DisplayInfo()
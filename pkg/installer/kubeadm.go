@@ -0,0 +1,270 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/providers"
+)
+
+const (
+	// kubeadmConfigPath is where the generated kubeadm configuration is written
+	kubeadmConfigPath = "/etc/kubernetes/kubeadm-config.yaml"
+
+	// kubeadmJoinConfigPath is where the join configuration of other nodes is written
+	kubeadmJoinConfigPath = "/etc/kubernetes/kubeadm-join.yaml"
+
+	// podNetworkCIDR matches the default network of the Flannel manifest
+	podNetworkCIDR = "10.244.0.0/16"
+
+	// containerdSocket is the CRI endpoint used by the kubelet
+	containerdSocket = "unix:///run/containerd/containerd.sock"
+)
+
+// KubeadmConfig holds the settings rendered into the kubeadm configuration file
+type KubeadmConfig struct {
+	KubernetesVersion string
+	PodSubnet         string
+	// ControlPlaneEndpoint is the shared API server address of multi control plane clusters
+	ControlPlaneEndpoint string
+	// CertSANs are extra names for the API server certificate, such as public addresses
+	CertSANs                   []string
+	KubeletExtraArgs           map[string]string
+	APIServerExtraArgs         map[string]string
+	ControllerManagerExtraArgs map[string]string
+	// VolumePluginDir relocates the flexvolume directory on read-only /usr systems
+	VolumePluginDir string
+	// AdvertiseAddress is the address a control plane's API server advertises when the
+	// provider chooses it rather than the default route
+	AdvertiseAddress string
+}
+
+// BuildKubeadmConfig creates the kubeadm configuration for the installer settings
+func (i *Installer) BuildKubeadmConfig() (*KubeadmConfig, error) {
+	kc := &KubeadmConfig{
+		KubernetesVersion:          i.Config.KubernetesVersion,
+		PodSubnet:                  podNetworkCIDR,
+		KubeletExtraArgs:           map[string]string{},
+		APIServerExtraArgs:         map[string]string{},
+		ControllerManagerExtraArgs: map[string]string{},
+	}
+	if i.Cluster != nil {
+		kc.ControlPlaneEndpoint = i.Cluster.Endpoint
+	}
+
+	// The address the installer connects to is often a public one that kubeadm does
+	// not know, and kubeconfig files point at it
+	kc.CertSANs = []string{i.Config.Host}
+
+	// Cloud provider flags apply to the kubelet and the controller manager
+	for name, value := range parseFlags(i.Provider.GetCloudProviderOptions()) {
+		kc.KubeletExtraArgs[name] = value
+		kc.ControllerManagerExtraArgs[name] = value
+	}
+
+	// Without a cloud controller manager the provider picks the node's address
+	if p, ok := i.Provider.(providers.NodeIPProvider); ok {
+		ip, err := p.NodeIP()
+		if err != nil {
+			return nil, err
+		}
+		kc.KubeletExtraArgs["node-ip"] = ip
+		kc.AdvertiseAddress = ip
+	}
+
+	if i.Config.IsImmutable() {
+		kc.VolumePluginDir = "/opt/libexec/kubernetes/kubelet-plugins/volume/exec/"
+		kc.ControllerManagerExtraArgs["flex-volume-plugin-dir"] = kc.VolumePluginDir
+	}
+
+	return kc, nil
+}
+
+// Render returns the multi-document kubeadm configuration. JSON documents are
+// valid YAML, so the structs are marshalled with encoding/json.
+func (kc *KubeadmConfig) Render() (string, error) {
+	apiVersion, v1beta4 := kc.apiVersion()
+
+	initConfig := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       "InitConfiguration",
+		"nodeRegistration": map[string]interface{}{
+			"criSocket":        containerdSocket,
+			"kubeletExtraArgs": extraArgs(kc.KubeletExtraArgs, v1beta4),
+		},
+	}
+	if kc.AdvertiseAddress != "" {
+		initConfig["localAPIEndpoint"] = map[string]interface{}{
+			"advertiseAddress": kc.AdvertiseAddress,
+		}
+	}
+
+	clusterConfig := map[string]interface{}{
+		"apiVersion":        apiVersion,
+		"kind":              "ClusterConfiguration",
+		"kubernetesVersion": "v" + kc.KubernetesVersion,
+		"networking": map[string]interface{}{
+			"podSubnet": kc.PodSubnet,
+		},
+		"apiServer": map[string]interface{}{
+			"certSANs":  kc.CertSANs,
+			"extraArgs": extraArgs(kc.APIServerExtraArgs, v1beta4),
+		},
+		"controllerManager": map[string]interface{}{
+			"extraArgs": extraArgs(kc.ControllerManagerExtraArgs, v1beta4),
+		},
+	}
+
+	if kc.ControlPlaneEndpoint != "" {
+		clusterConfig["controlPlaneEndpoint"] = kc.ControlPlaneEndpoint
+	}
+
+	kubeletConfig := map[string]interface{}{
+		"apiVersion":   "kubelet.config.k8s.io/v1beta1",
+		"kind":         "KubeletConfiguration",
+		"cgroupDriver": "systemd",
+	}
+	if kc.VolumePluginDir != "" {
+		kubeletConfig["volumePluginDir"] = kc.VolumePluginDir
+	}
+
+	return renderDocuments(initConfig, clusterConfig, kubeletConfig)
+}
+
+// RenderJoin returns the kubeadm join configuration for the join command printed by
+// "kubeadm token create --print-join-command". The kubelet gets the same extra
+// arguments as on the first control plane, which a plain "kubeadm join" cannot pass.
+// A certificate key joins the node as an additional control plane.
+func (kc *KubeadmConfig) RenderJoin(joinCommand, certificateKey string) (string, error) {
+	endpoint, token, caCertHashes, err := parseJoinCommand(joinCommand)
+	if err != nil {
+		return "", err
+	}
+	apiVersion, v1beta4 := kc.apiVersion()
+
+	joinConfig := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       "JoinConfiguration",
+		"discovery": map[string]interface{}{
+			"bootstrapToken": map[string]interface{}{
+				"apiServerEndpoint": endpoint,
+				"token":             token,
+				"caCertHashes":      caCertHashes,
+			},
+		},
+		"nodeRegistration": map[string]interface{}{
+			"criSocket":        containerdSocket,
+			"kubeletExtraArgs": extraArgs(kc.KubeletExtraArgs, v1beta4),
+		},
+	}
+	if certificateKey != "" {
+		controlPlane := map[string]interface{}{
+			"certificateKey": certificateKey,
+		}
+		if kc.AdvertiseAddress != "" {
+			controlPlane["localAPIEndpoint"] = map[string]interface{}{
+				"advertiseAddress": kc.AdvertiseAddress,
+			}
+		}
+		joinConfig["controlPlane"] = controlPlane
+	}
+
+	return renderDocuments(joinConfig)
+}
+
+// apiVersion returns the kubeadm API version for the Kubernetes version and whether it
+// is v1beta4, which replaced extraArgs maps with name/value lists in Kubernetes 1.31
+func (kc *KubeadmConfig) apiVersion() (string, bool) {
+	if minorVersion(kc.KubernetesVersion) < 31 {
+		return "kubeadm.k8s.io/v1beta3", false
+	}
+	return "kubeadm.k8s.io/v1beta4", true
+}
+
+// renderDocuments joins the documents into one multi-document file
+func renderDocuments(docs ...interface{}) (string, error) {
+	var documents []string
+	for _, doc := range docs {
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to render kubeadm configuration: %v", err)
+		}
+		documents = append(documents, string(data))
+	}
+
+	return strings.Join(documents, "\n---\n") + "\n", nil
+}
+
+// parseJoinCommand extracts the API server endpoint, bootstrap token and CA
+// certificate hashes from a "kubeadm join" command
+func parseJoinCommand(command string) (endpoint, token string, caCertHashes []string, err error) {
+	fields := strings.Fields(command)
+	for n := 0; n < len(fields); n++ {
+		name, value, hasValue := strings.Cut(fields[n], "=")
+		switch name {
+		case "--token", "--discovery-token-ca-cert-hash":
+			if !hasValue && n+1 < len(fields) {
+				n++
+				value = fields[n]
+			}
+			if name == "--token" {
+				token = value
+			} else {
+				caCertHashes = append(caCertHashes, value)
+			}
+		case "join":
+			if n+1 < len(fields) && !strings.HasPrefix(fields[n+1], "-") {
+				endpoint = fields[n+1]
+			}
+		}
+	}
+
+	if endpoint == "" || token == "" {
+		return "", "", nil, fmt.Errorf("failed to parse join command: missing API server endpoint or token")
+	}
+	return endpoint, token, caCertHashes, nil
+}
+
+// extraArgs converts a flag map into the extraArgs format of the kubeadm API version
+func extraArgs(args map[string]string, v1beta4 bool) interface{} {
+	if !v1beta4 {
+		return args
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]map[string]string, 0, len(args))
+	for _, name := range names {
+		list = append(list, map[string]string{"name": name, "value": args[name]})
+	}
+	return list
+}
+
+// parseFlags splits a "--name=value --other=value" string into a map
+func parseFlags(flags string) map[string]string {
+	parsed := make(map[string]string)
+	for _, field := range strings.Fields(flags) {
+		name, value, _ := strings.Cut(strings.TrimLeft(field, "-"), "=")
+		if name != "" {
+			parsed[name] = value
+		}
+	}
+	return parsed
+}
+
+// minorVersion returns the minor component of a "1.33.4" style version
+func minorVersion(version string) int {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) < 2 {
+		return 0
+	}
+	var minor int
+	fmt.Sscanf(parts[1], "%d", &minor)
+	return minor
+}
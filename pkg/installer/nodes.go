@@ -0,0 +1,177 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// adminKubeconfigPath is the cluster admin kubeconfig written by kubeadm
+const adminKubeconfigPath = "/etc/kubernetes/admin.conf"
+
+// kubectl runs kubectl as root with the admin kubeconfig, independent of the SSH user's setup
+const kubectl = "sudo kubectl --kubeconfig " + adminKubeconfigPath
+
+// Node is a cluster node as reported by the API server
+type Node struct {
+	Name           string   `json:"name"`
+	Roles          []string `json:"roles"`
+	Ready          bool     `json:"ready"`
+	Unschedulable  bool     `json:"unschedulable"`
+	KubeletVersion string   `json:"kubeletVersion"`
+	InternalIP     string   `json:"internalIP"`
+	// ProviderID is set by the cloud controller manager once it initializes the node
+	ProviderID string `json:"providerID,omitempty"`
+}
+
+// nodeList is the subset of "kubectl get nodes -o json" that is read
+type nodeList struct {
+	Items []struct {
+		Metadata struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+		Spec struct {
+			Unschedulable bool   `json:"unschedulable"`
+			ProviderID    string `json:"providerID"`
+		} `json:"spec"`
+		Status struct {
+			Conditions []struct {
+				Type   string `json:"type"`
+				Status string `json:"status"`
+			} `json:"conditions"`
+			Addresses []struct {
+				Type    string `json:"type"`
+				Address string `json:"address"`
+			} `json:"addresses"`
+			NodeInfo struct {
+				KubeletVersion string `json:"kubeletVersion"`
+			} `json:"nodeInfo"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// GetNodes lists the cluster nodes from a control-plane host
+func (i *Installer) GetNodes() ([]Node, error) {
+	output, err := i.Client.RunCommand(kubectl + " get nodes -o json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %v", err)
+	}
+
+	var list nodeList
+	if err := json.Unmarshal([]byte(output), &list); err != nil {
+		return nil, fmt.Errorf("failed to parse node list: %v", err)
+	}
+
+	nodes := make([]Node, 0, len(list.Items))
+	for _, item := range list.Items {
+		node := Node{
+			Name:           item.Metadata.Name,
+			Unschedulable:  item.Spec.Unschedulable,
+			ProviderID:     item.Spec.ProviderID,
+			KubeletVersion: item.Status.NodeInfo.KubeletVersion,
+		}
+		for label := range item.Metadata.Labels {
+			if role, ok := strings.CutPrefix(label, "node-role.kubernetes.io/"); ok {
+				node.Roles = append(node.Roles, role)
+			}
+		}
+		sort.Strings(node.Roles)
+		for _, condition := range item.Status.Conditions {
+			if condition.Type == "Ready" {
+				node.Ready = condition.Status == "True"
+			}
+		}
+		for _, address := range item.Status.Addresses {
+			if address.Type == "InternalIP" {
+				node.InternalIP = address.Address
+			}
+		}
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+// AdminKubeconfig reads the cluster admin kubeconfig from a control-plane host
+func (i *Installer) AdminKubeconfig() ([]byte, error) {
+	output, err := i.Client.RunCommand("sudo cat " + adminKubeconfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the admin kubeconfig: %v", err)
+	}
+	return []byte(output), nil
+}
+
+// FindNode returns the name of the cluster node running on the host, matching its
+// hostname or one of its addresses. It returns an empty name when the host is not a node.
+func (i *Installer) FindNode(nodes []Node) (string, error) {
+	hostname, err := i.Client.RunCommand("hostname")
+	if err != nil {
+		return "", fmt.Errorf("failed to get hostname: %v", err)
+	}
+	hostname = strings.ToLower(strings.TrimSpace(hostname))
+
+	addresses, err := i.Client.RunCommand("ip -o addr show | awk '{split($4, a, \"/\"); print a[1]}'")
+	if err != nil {
+		return "", fmt.Errorf("failed to get addresses: %v", err)
+	}
+	isAddress := make(map[string]bool)
+	for _, address := range strings.Fields(addresses) {
+		isAddress[address] = true
+	}
+	isAddress[i.Config.Host] = true
+
+	for _, node := range nodes {
+		if node.Name == hostname || isAddress[node.InternalIP] {
+			return node.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// CordonNode marks a node unschedulable, run from a control-plane host
+func (i *Installer) CordonNode(name string) error {
+	return i.Client.RunCommands([]string{kubectl + " cordon " + name})
+}
+
+// UncordonNode marks a node schedulable again, run from a control-plane host
+func (i *Installer) UncordonNode(name string) error {
+	return i.Client.RunCommands([]string{kubectl + " uncordon " + name})
+}
+
+// WaitForNode waits until a node is Ready with its kubelet at the given version, run
+// from a control-plane host
+func (i *Installer) WaitForNode(ctx context.Context, name, version string, timeout time.Duration) error {
+	err := poll(ctx, timeout, func() (bool, string, error) {
+		nodes, err := i.GetNodes()
+		if err != nil {
+			return false, "", err
+		}
+		for _, node := range nodes {
+			if node.Name == name {
+				ready := node.Ready && strings.TrimPrefix(node.KubeletVersion, "v") == version
+				return ready, fmt.Sprintf("ready=%t, kubelet %s", node.Ready, node.KubeletVersion), nil
+			}
+		}
+		return false, "not found", nil
+	})
+	if err != nil {
+		return fmt.Errorf("node %s did not become Ready at v%s: %v", name, version, err)
+	}
+	return nil
+}
+
+// DrainNode evicts the workloads of a node, run from a control-plane host
+func (i *Installer) DrainNode(name string) error {
+	return i.Client.RunCommands([]string{
+		kubectl + " drain " + name + " --ignore-daemonsets --delete-emptydir-data --force --timeout=300s",
+	})
+}
+
+// DeleteNode removes a node from the cluster, run from a control-plane host
+func (i *Installer) DeleteNode(name string) error {
+	return i.Client.RunCommands([]string{kubectl + " delete node " + name + " --ignore-not-found"})
+}
@@ -0,0 +1,117 @@
+package installer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+)
+
+// Plan lists everything an installation would do on a host
+type Plan struct {
+	Host              string     `json:"host"`
+	Role              string     `json:"role"`
+	Provider          string     `json:"provider"`
+	Distribution      string     `json:"distribution"`
+	Arch              string     `json:"arch"`
+	KubernetesVersion string     `json:"kubernetesVersion"`
+	Steps             []PlanStep `json:"steps"`
+}
+
+// PlanStep lists the commands and file writes of one installation step
+type PlanStep struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Commands    []string           `json:"commands"`
+	Files       []ssh.RecordedFile `json:"files,omitempty"`
+}
+
+// BuildPlan runs every registered step for the host role against a recording executor
+// and returns the commands and files it would produce. The configuration must already
+// have its distribution and architecture set, either from flags or from DetectPlatform.
+func BuildPlan(registry *Registry, cfg *config.Config, role string) (*Plan, error) {
+	steps, err := registry.Ordered()
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := ssh.NewRecorder(cfg.Password)
+	planner := NewInstaller(recorder, cfg)
+	// The plan itself is the output, so progress and provider messages are dropped
+	planner.SetLogger(nil)
+	planner.Role = role
+	if role != RoleControlPlane {
+		// Other nodes join a control plane that does not exist yet
+		planner.Cluster = &Cluster{
+			joinCommand:    "kubeadm join <control-plane>:" + APIServerPort + " --token <token> --discovery-token-ca-cert-hash <hash>",
+			certificateKey: "<certificate-key>",
+		}
+	}
+
+	plan := &Plan{
+		Host:              cfg.Host,
+		Role:              role,
+		Provider:          string(cfg.Provider),
+		Distribution:      string(cfg.Distribution),
+		Arch:              string(cfg.Arch),
+		KubernetesVersion: cfg.KubernetesVersion,
+	}
+
+	for _, step := range steps {
+		if !step.appliesTo(role) {
+			continue
+		}
+		if step.Skip != nil {
+			if skip, err := step.Skip(planner); err != nil || skip {
+				continue
+			}
+		}
+
+		recorder.SetStep(step.Name)
+		if err := runStepHooks(step.Pre, planner); err != nil {
+			return nil, fmt.Errorf("failed to plan step %s: %v", step.Name, err)
+		}
+		if err := step.Run(planner); err != nil {
+			return nil, fmt.Errorf("failed to plan step %s: %v", step.Name, err)
+		}
+		if err := runStepHooks(step.Post, planner); err != nil {
+			return nil, fmt.Errorf("failed to plan step %s: %v", step.Name, err)
+		}
+
+		planStep := PlanStep{Name: step.Name, Description: step.Description}
+		for _, cmd := range recorder.Commands() {
+			if cmd.Step == step.Name {
+				planStep.Commands = append(planStep.Commands, cmd.Command)
+			}
+		}
+		for _, file := range recorder.Files() {
+			if file.Step == step.Name {
+				planStep.Files = append(planStep.Files, file)
+			}
+		}
+		plan.Steps = append(plan.Steps, planStep)
+	}
+
+	return plan, nil
+}
+
+// WriteText writes a human-readable rendering of the plan
+func (p *Plan) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Host: %s (%s)\n", p.Host, p.Role)
+	fmt.Fprintf(w, "Provider: %s, Distribution: %s (%s), Kubernetes: v%s\n", p.Provider, p.Distribution, p.Arch, p.KubernetesVersion)
+
+	for n, step := range p.Steps {
+		fmt.Fprintf(w, "\n[%d] %s (%s)\n", n+1, step.Description, step.Name)
+		for _, file := range step.Files {
+			fmt.Fprintf(w, "  write %s (mode %04o)\n", file.Path, file.Mode.Perm())
+			for _, line := range strings.Split(strings.TrimRight(file.Content, "\n"), "\n") {
+				fmt.Fprintf(w, "    | %s\n", line)
+			}
+		}
+		for _, cmd := range step.Commands {
+			fmt.Fprintf(w, "  $ %s\n", strings.ReplaceAll(cmd, "\n", "\n    "))
+		}
+	}
+}
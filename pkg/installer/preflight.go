@@ -0,0 +1,405 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
+)
+
+// Severity tells whether a failed preflight check stops the installation
+type Severity string
+
+// Preflight check severities
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// IgnoreAllPreflight in the ignore list ignores every preflight check
+const IgnoreAllPreflight = "all"
+
+// PreflightCheck verifies that a host can run Kubernetes before anything is installed
+type PreflightCheck struct {
+	// Name identifies the check in reports and ignore lists
+	Name string
+	// Severity of a failure
+	Severity Severity
+	// Roles limits the check to hosts with one of the roles; empty means every host
+	Roles []string
+	// Run returns an error describing why the host fails the check
+	Run func(i *Installer) error
+	// Unique is set instead of Run for checks of values that must differ between
+	// all hosts, such as hardware identifiers
+	Unique func(i *Installer) ([]string, error)
+}
+
+// PreflightResult is a failed preflight check on one host
+type PreflightResult struct {
+	Host     string   `json:"host"`
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	// Ignored is set for errors turned into warnings by the ignore list
+	Ignored bool `json:"ignored,omitempty"`
+}
+
+// PreflightError is returned when preflight checks with error severity fail
+type PreflightError struct {
+	// Results lists every failed check, including warnings and ignored errors
+	Results []PreflightResult
+}
+
+// Error renders all failures as one table
+func (e *PreflightError) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString("preflight checks failed (ignore a check with --ignore-preflight=<check>):\n")
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tCHECK\tSEVERITY\tMESSAGE")
+	for _, r := range e.Results {
+		severity := string(r.Severity)
+		if r.Ignored {
+			severity += " (ignored)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Host, r.Check, severity, r.Message)
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// Minimum resources per role
+const (
+	minControlPlaneCPUs      = 2
+	minWorkerCPUs            = 1
+	minControlPlaneMemoryMiB = 1700
+	minWorkerMemoryMiB       = 1024
+	minFreeDiskGiB           = 10
+)
+
+// controlPlanePorts are bound by the API server, etcd, kubelet, controller manager and scheduler
+var controlPlanePorts = []int{6443, 2379, 2380, 10250, 10257, 10259}
+
+// workerPorts are bound by the kubelet and kube-proxy
+var workerPorts = []int{10250, 10256}
+
+// DefaultPreflightChecks returns the built-in preflight checks
+func DefaultPreflightChecks() []*PreflightCheck {
+	return []*PreflightCheck{
+		{Name: "cpu", Severity: SeverityError, Run: checkCPU},
+		{Name: "memory", Severity: SeverityError, Run: checkMemory},
+		{Name: "disk", Severity: SeverityError, Run: checkDisk},
+		{Name: "kernel", Severity: SeverityError, Run: checkKernel},
+		{Name: "cgroups", Severity: SeverityWarning, Run: checkCgroups},
+		{Name: "kernel-modules", Severity: SeverityError, Run: checkKernelModules},
+		{Name: "ports", Severity: SeverityError, Run: checkPorts},
+		{Name: "product-uuid", Severity: SeverityError, Unique: productUUID},
+		{Name: "mac-address", Severity: SeverityError, Unique: macAddresses},
+		{Name: "time-sync", Severity: SeverityWarning, Run: checkTimeSync},
+		{Name: "dns", Severity: SeverityError, Run: checkDNS},
+		// Swap is turned off by the prerequisites step
+		{Name: "swap", Severity: SeverityWarning, Run: checkSwap},
+	}
+}
+
+// Preflight runs the checks on every host that is not yet part of a cluster and
+// returns all failures. It returns a PreflightError if a check with error severity
+// fails and is not in the ignore list.
+func (e *Engine) Preflight(ctx context.Context, checks []*PreflightCheck, ignore []string) ([]PreflightResult, error) {
+	ignored := make(map[string]bool)
+	for _, name := range ignore {
+		ignored[name] = true
+	}
+	for name := range ignored {
+		if name != IgnoreAllPreflight && findPreflightCheck(checks, name) == nil {
+			var names []string
+			for _, check := range checks {
+				names = append(names, check.Name)
+			}
+			return nil, fmt.Errorf("unknown preflight check '%s': use %s or one of %s", name, IgnoreAllPreflight, strings.Join(names, ", "))
+		}
+	}
+
+	var results []PreflightResult
+	fail := func(host string, check *PreflightCheck, message string) {
+		results = append(results, PreflightResult{
+			Host:     host,
+			Check:    check.Name,
+			Severity: check.Severity,
+			Message:  message,
+			Ignored:  check.Severity == SeverityError && (ignored[check.Name] || ignored[IgnoreAllPreflight]),
+		})
+	}
+
+	// owners maps each value of a unique check to the first host reporting it
+	owners := make(map[string]map[string]string)
+
+	for _, host := range e.Hosts {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		i := host.Installer
+		// Resumed installations would fail the port checks against their own components
+		if done, _ := probe("test -f /etc/kubernetes/kubelet.conf")(i); done {
+			i.Log.Emit(events.Event{Type: events.StepSkipped, Step: "preflight", Message: "Preflight checks skipped, the host is already part of a cluster"})
+			continue
+		}
+
+		start := time.Now()
+		i.Log.Emit(events.Event{Type: events.StepStarted, Step: "preflight", Message: "Running preflight checks"})
+		failed := len(results)
+
+		for _, check := range checks {
+			if !hasRole(check.Roles, host.Role) {
+				continue
+			}
+
+			if check.Unique == nil {
+				if err := check.Run(i); err != nil {
+					fail(host.Name(), check, err.Error())
+				}
+				continue
+			}
+
+			values, err := check.Unique(i)
+			if err != nil {
+				fail(host.Name(), check, err.Error())
+				continue
+			}
+			if owners[check.Name] == nil {
+				owners[check.Name] = make(map[string]string)
+			}
+			for _, value := range values {
+				if owner, ok := owners[check.Name][value]; ok && owner != host.Name() {
+					fail(host.Name(), check, fmt.Sprintf("%s is also used by %s", value, owner))
+				} else {
+					owners[check.Name][value] = host.Name()
+				}
+			}
+		}
+
+		for _, r := range results[failed:] {
+			if r.Severity == SeverityWarning || r.Ignored {
+				i.Log.Warnf("Preflight check %s: %s", r.Check, r.Message)
+			}
+		}
+		i.Log.Emit(events.Event{Type: events.StepFinished, Step: "preflight", Message: "Running preflight checks", Duration: time.Since(start)})
+	}
+
+	for _, r := range results {
+		if r.Severity == SeverityError && !r.Ignored {
+			return results, &PreflightError{Results: results}
+		}
+	}
+	return results, nil
+}
+
+// findPreflightCheck returns the check with the name, or nil
+func findPreflightCheck(checks []*PreflightCheck, name string) *PreflightCheck {
+	for _, check := range checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	return nil
+}
+
+// remoteInt runs a command that prints a single integer
+func (i *Installer) remoteInt(command string) (int, error) {
+	output, err := i.Client.RunCommand(command)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output '%s'", strings.TrimSpace(output))
+	}
+	return value, nil
+}
+
+// checkCPU requires two CPUs on control planes and one on workers
+func checkCPU(i *Installer) error {
+	cpus, err := i.remoteInt("nproc")
+	if err != nil {
+		return fmt.Errorf("failed to count CPUs: %v", err)
+	}
+	required := minWorkerCPUs
+	if i.Role == RoleControlPlane {
+		required = minControlPlaneCPUs
+	}
+	if cpus < required {
+		return fmt.Errorf("%d CPUs found, %d required for a %s", cpus, required, i.Role)
+	}
+	return nil
+}
+
+// checkMemory requires the memory kubeadm expects on control planes
+func checkMemory(i *Installer) error {
+	memory, err := i.remoteInt("awk '/^MemTotal:/ {print int($2 / 1024)}' /proc/meminfo")
+	if err != nil {
+		return fmt.Errorf("failed to read memory size: %v", err)
+	}
+	required := minWorkerMemoryMiB
+	if i.Role == RoleControlPlane {
+		required = minControlPlaneMemoryMiB
+	}
+	if memory < required {
+		return fmt.Errorf("%d MiB of memory found, %d MiB required for a %s", memory, required, i.Role)
+	}
+	return nil
+}
+
+// checkDisk requires free space for images, etcd and logs under /var
+func checkDisk(i *Installer) error {
+	free, err := i.remoteInt("df -Pk /var | awk 'NR == 2 {print int($4 / 1048576)}'")
+	if err != nil {
+		return fmt.Errorf("failed to read free disk space: %v", err)
+	}
+	if free < minFreeDiskGiB {
+		return fmt.Errorf("%d GiB free on /var, %d GiB required", free, minFreeDiskGiB)
+	}
+	return nil
+}
+
+// kernelVersion returns the major and minor version of the running kernel
+func (i *Installer) kernelVersion() (int, int, error) {
+	output, err := i.Client.RunCommand("uname -r")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get kernel version: %v", err)
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(strings.TrimSpace(output), "%d.%d", &major, &minor); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse kernel version '%s'", strings.TrimSpace(output))
+	}
+	return major, minor, nil
+}
+
+// checkKernel requires the oldest kernel kubeadm accepts, 4.18, which EL8 ships with
+// the fixes of later kernels backported
+func checkKernel(i *Installer) error {
+	major, minor, err := i.kernelVersion()
+	if err != nil {
+		return err
+	}
+	if major < 4 || (major == 4 && minor < 18) {
+		return fmt.Errorf("kernel %d.%d found, 4.18 or newer required", major, minor)
+	}
+	return nil
+}
+
+// checkCgroups warns about cgroup v1, which recent kubelets refuse, and about cgroup v2
+// on kernels older than 5.8
+func checkCgroups(i *Installer) error {
+	output, err := i.Client.RunCommand("stat -fc %T /sys/fs/cgroup")
+	if err != nil {
+		return fmt.Errorf("failed to detect cgroup version: %v", err)
+	}
+	if strings.TrimSpace(output) != "cgroup2fs" {
+		return fmt.Errorf("cgroup v1 found; it is deprecated and no longer supported by recent kubelets")
+	}
+
+	major, minor, err := i.kernelVersion()
+	if err != nil {
+		return err
+	}
+	if major < 5 || (major == 5 && minor < 8) {
+		return fmt.Errorf("cgroup v2 on kernel %d.%d; 5.8 or newer is recommended", major, minor)
+	}
+	return nil
+}
+
+// checkKernelModules requires the modules loaded by the prerequisites step to be available
+func checkKernelModules(i *Installer) error {
+	output, err := i.Client.RunCommand("for m in overlay br_netfilter; do test -d /sys/module/$m || modinfo $m > /dev/null 2>&1 || echo $m; done")
+	if err != nil {
+		return fmt.Errorf("failed to check kernel modules: %v", err)
+	}
+	if missing := strings.Fields(output); len(missing) > 0 {
+		return fmt.Errorf("kernel modules not available: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkPorts requires the ports of the Kubernetes components to be free
+func checkPorts(i *Installer) error {
+	output, err := i.Client.RunCommand("ss -Htln | awk '{print $4}'")
+	if err != nil {
+		return fmt.Errorf("failed to list listening ports: %v", err)
+	}
+	listening := make(map[string]bool)
+	for _, address := range strings.Fields(output) {
+		if n := strings.LastIndex(address, ":"); n >= 0 {
+			listening[address[n+1:]] = true
+		}
+	}
+
+	ports := workerPorts
+	if i.Role == RoleControlPlane {
+		ports = controlPlanePorts
+	}
+	var used []string
+	for _, port := range ports {
+		if listening[strconv.Itoa(port)] {
+			used = append(used, strconv.Itoa(port))
+		}
+	}
+	if len(used) > 0 {
+		return fmt.Errorf("ports already in use: %s", strings.Join(used, ", "))
+	}
+	return nil
+}
+
+// productUUID returns the hardware UUID, which kubelets use to tell nodes apart
+func productUUID(i *Installer) ([]string, error) {
+	output, err := i.Client.RunCommand("sudo cat /sys/class/dmi/id/product_uuid")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read product_uuid: %v", err)
+	}
+	return strings.Fields(strings.ToLower(output)), nil
+}
+
+// macAddresses returns the MAC addresses of the physical network interfaces
+func macAddresses(i *Installer) ([]string, error) {
+	output, err := i.Client.RunCommand("for d in /sys/class/net/*; do test -e $d/device && cat $d/address; done; true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MAC addresses: %v", err)
+	}
+	return strings.Fields(strings.ToLower(output)), nil
+}
+
+// checkTimeSync warns when the clock is not synchronized, which breaks certificates
+func checkTimeSync(i *Installer) error {
+	output, _, err := i.Client.RunCommandWithOutput("timedatectl show -p NTPSynchronized --value")
+	if err != nil {
+		return fmt.Errorf("failed to query time synchronization: %v", err)
+	}
+	if strings.TrimSpace(output) != "yes" {
+		return fmt.Errorf("the system clock is not synchronized with NTP")
+	}
+	return nil
+}
+
+// checkDNS requires the image registry to resolve
+func checkDNS(i *Installer) error {
+	if _, _, err := i.Client.RunCommandWithOutput("getent hosts registry.k8s.io"); err != nil {
+		return fmt.Errorf("registry.k8s.io does not resolve")
+	}
+	return nil
+}
+
+// checkSwap warns that swap is enabled
+func checkSwap(i *Installer) error {
+	output, _, err := i.Client.RunCommandWithOutput("swapon --noheadings")
+	if err != nil {
+		return fmt.Errorf("failed to list swap devices: %v", err)
+	}
+	if strings.TrimSpace(output) != "" {
+		return fmt.Errorf("swap is enabled and will be turned off")
+	}
+	return nil
+}
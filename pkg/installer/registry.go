@@ -0,0 +1,271 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Node roles used to target steps at hosts
+const (
+	RoleControlPlane = "control-plane"
+	RoleWorker       = "worker"
+)
+
+// RetryPolicy controls how often a failed step is retried on a host
+type RetryPolicy struct {
+	// Attempts is the total number of tries; zero or one means no retries
+	Attempts int
+	// Delay is the wait before the first retry
+	Delay time.Duration
+	// Backoff multiplies the delay after every retry; values below 1 keep it constant
+	Backoff float64
+}
+
+// Step is a named installation step that runs on every host matching its roles
+type Step struct {
+	// Name identifies the step for dependencies, --from-step and --only-step
+	Name string
+	// Description is shown while the step runs
+	Description string
+	// DependsOn lists steps that must run before this one
+	DependsOn []string
+	// Roles limits the step to hosts with one of the roles; empty means every host
+	Roles []string
+	// Run performs the step on one host
+	Run func(i *Installer) error
+	// Check probes the host and returns true if the step is already done.
+	// Steps without a probe rely on the recorded state only.
+	Check func(i *Installer) (bool, error)
+	// Skip returns true if the step does not apply to the host
+	Skip func(i *Installer) (bool, error)
+	// Pre and Post hooks run on the host before and after the step
+	Pre  []func(i *Installer) error
+	Post []func(i *Installer) error
+	// Retry controls retries of Run
+	Retry RetryPolicy
+}
+
+// appliesTo returns true if the step targets hosts with the role
+func (s *Step) appliesTo(role string) bool {
+	return hasRole(s.Roles, role)
+}
+
+// hasRole returns true if the role is in the list or the list is empty
+func hasRole(roles []string, role string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry holds steps in their preferred order
+type Registry struct {
+	steps []*Step
+}
+
+// NewRegistry creates an empty step registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry returns a registry with the built-in installation steps
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(&Step{
+		Name:        "prerequisites",
+		Description: "Installing prerequisites",
+		Run:         (*Installer).InstallPrerequisites,
+		Check: probe("test -f /etc/modules-load.d/k8s.conf && test -f /etc/sysctl.d/k8s.conf && " +
+			"test -z \"$(swapon --noheadings 2>/dev/null)\""),
+	})
+	r.Register(&Step{
+		Name:        "container-runtime",
+		Description: "Installing container runtime",
+		DependsOn:   []string{"prerequisites"},
+		Run:         (*Installer).InstallContainerRuntime,
+		Check:       probe("systemctl is-active --quiet containerd && grep -q 'SystemdCgroup = true' /etc/containerd/config.toml"),
+		Retry:       RetryPolicy{Attempts: 3, Delay: 10 * time.Second, Backoff: 2},
+	})
+	r.Register(&Step{
+		Name:        "kubernetes-components",
+		Description: "Installing Kubernetes components",
+		DependsOn:   []string{"container-runtime"},
+		Run:         (*Installer).InstallKubernetesComponents,
+		Check: func(i *Installer) (bool, error) {
+			return probe(fmt.Sprintf("command -v kubelet && command -v kubectl && test \"$(kubeadm version -o short)\" = v%s",
+				i.Config.KubernetesVersion))(i)
+		},
+		Retry: RetryPolicy{Attempts: 3, Delay: 10 * time.Second, Backoff: 2},
+	})
+	r.Register(&Step{
+		Name:        "init-cluster",
+		Description: "Initializing Kubernetes cluster",
+		DependsOn:   []string{"kubernetes-components"},
+		Roles:       []string{RoleControlPlane},
+		Run:         (*Installer).InitializeCluster,
+		Check:       probe("test -f /etc/kubernetes/admin.conf && test -f $HOME/.kube/config && kubectl -n kube-flannel get daemonset kube-flannel-ds"),
+		Skip:        notPrimary,
+	})
+	r.Register(&Step{
+		Name:        "join-cluster",
+		Description: "Joining node to the cluster",
+		DependsOn:   []string{"kubernetes-components", "init-cluster"},
+		Run:         (*Installer).JoinCluster,
+		Check:       probe("test -f /etc/kubernetes/kubelet.conf"),
+		Skip: func(i *Installer) (bool, error) {
+			return i.Cluster.IsPrimary(i), nil
+		},
+	})
+	r.Register(&Step{
+		Name:        "cloud-provider",
+		Description: "Configuring cloud provider integration",
+		DependsOn:   []string{"init-cluster"},
+		Roles:       []string{RoleControlPlane},
+		Run:         (*Installer).SetupCloudProviderIntegration,
+		Check:       (*Installer).cloudProviderInstalled,
+		Skip:        notPrimary,
+	})
+	return r
+}
+
+// notPrimary skips steps that only run on the control plane initializing the cluster
+func notPrimary(i *Installer) (bool, error) {
+	return !i.Cluster.IsPrimary(i), nil
+}
+
+// probe returns a check that succeeds when the command exits with status 0
+func probe(command string) func(i *Installer) (bool, error) {
+	return func(i *Installer) (bool, error) {
+		_, _, err := i.Client.RunCommandWithOutput(command)
+		return err == nil, nil
+	}
+}
+
+// Register appends a step
+func (r *Registry) Register(step *Step) {
+	r.steps = append(r.steps, step)
+}
+
+// Clone returns a copy of the registry whose steps can be added to and rewired
+// without changing the original
+func (r *Registry) Clone() *Registry {
+	clone := &Registry{steps: make([]*Step, len(r.steps))}
+	for n, step := range r.steps {
+		copied := *step
+		copied.DependsOn = append([]string(nil), step.DependsOn...)
+		clone.steps[n] = &copied
+	}
+	return clone
+}
+
+// InsertBefore adds a step that runs before an existing step
+func (r *Registry) InsertBefore(name string, step *Step) error {
+	index := r.index(name)
+	if index < 0 {
+		return fmt.Errorf("unknown step '%s': use one of %s", name, strings.Join(r.Names(), ", "))
+	}
+
+	target := r.steps[index]
+	step.DependsOn = append(step.DependsOn, target.DependsOn...)
+	target.DependsOn = append(target.DependsOn, step.Name)
+
+	r.steps = append(r.steps[:index], append([]*Step{step}, r.steps[index:]...)...)
+	return nil
+}
+
+// InsertAfter adds a step that runs after an existing step
+func (r *Registry) InsertAfter(name string, step *Step) error {
+	index := r.index(name)
+	if index < 0 {
+		return fmt.Errorf("unknown step '%s': use one of %s", name, strings.Join(r.Names(), ", "))
+	}
+
+	step.DependsOn = append(step.DependsOn, name)
+
+	index++
+	r.steps = append(r.steps[:index], append([]*Step{step}, r.steps[index:]...)...)
+	return nil
+}
+
+// Get returns the step with the name, or nil
+func (r *Registry) Get(name string) *Step {
+	if index := r.index(name); index >= 0 {
+		return r.steps[index]
+	}
+	return nil
+}
+
+// Names returns the names of all registered steps in registration order
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.steps))
+	for n, step := range r.steps {
+		names[n] = step.Name
+	}
+	return names
+}
+
+// Ordered returns the steps sorted so that every step follows its dependencies.
+// Independent steps keep their registration order.
+func (r *Registry) Ordered() ([]*Step, error) {
+	seen := make(map[string]bool)
+	for _, step := range r.steps {
+		if seen[step.Name] {
+			return nil, fmt.Errorf("duplicate step '%s'", step.Name)
+		}
+		seen[step.Name] = true
+	}
+	for _, step := range r.steps {
+		for _, dep := range step.DependsOn {
+			if !seen[dep] {
+				return nil, fmt.Errorf("step '%s' depends on unknown step '%s'", step.Name, dep)
+			}
+		}
+	}
+
+	done := make(map[string]bool)
+	var ordered []*Step
+	for len(ordered) < len(r.steps) {
+		progressed := false
+		for _, step := range r.steps {
+			if done[step.Name] || !dependenciesDone(step, done) {
+				continue
+			}
+			done[step.Name] = true
+			ordered = append(ordered, step)
+			progressed = true
+			// Restart from the top so earlier-registered steps keep priority
+			break
+		}
+		if !progressed {
+			return nil, fmt.Errorf("steps have a dependency cycle")
+		}
+	}
+
+	return ordered, nil
+}
+
+// dependenciesDone returns true if all dependencies of the step are done
+func dependenciesDone(step *Step, done map[string]bool) bool {
+	for _, dep := range step.DependsOn {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// index returns the position of the named step, or -1
+func (r *Registry) index(name string) int {
+	for n, step := range r.steps {
+		if step.Name == name {
+			return n
+		}
+	}
+	return -1
+}
@@ -0,0 +1,107 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+)
+
+// ResetOptions controls how much of the installation Reset reverts
+type ResetOptions struct {
+	// Uninstall removes the Kubernetes and container runtime packages and their repositories
+	Uninstall bool
+	// RevertSystem removes the kernel module and sysctl files written by InstallPrerequisites
+	RevertSystem bool
+}
+
+// Reset reverts kubeadm's changes to the host, removes the cluster's network and
+// data directories, and forgets its installation state
+func (i *Installer) Reset(opts ResetOptions) error {
+	commands := []string{
+		// kubeadm may be missing after a partial installation
+		"if command -v kubeadm > /dev/null; then sudo kubeadm reset -f --cri-socket " + containerdSocket + "; fi",
+		"sudo systemctl stop kubelet 2>/dev/null || true",
+		// CNI configuration, state and interfaces
+		"sudo rm -rf /etc/cni/net.d /var/lib/cni /run/flannel",
+		"for link in cni0 flannel.1; do sudo ip link delete $link 2>/dev/null || true; done",
+		// Rules programmed by kube-proxy and the CNI
+		"if command -v iptables > /dev/null; then sudo iptables -F && sudo iptables -t nat -F && sudo iptables -t mangle -F && sudo iptables -X; fi",
+		"if command -v ipvsadm > /dev/null; then sudo ipvsadm --clear; fi",
+		// Cluster configuration and data
+		"sudo rm -rf /etc/kubernetes /var/lib/etcd /var/lib/kubelet " + kubeadmConfigPath,
+		"rm -f $HOME/.kube/config",
+	}
+
+	if opts.Uninstall {
+		uninstall, err := i.uninstallCommands()
+		if err != nil {
+			return err
+		}
+		commands = append(commands, uninstall...)
+	}
+
+	if opts.RevertSystem {
+		commands = append(commands,
+			"sudo rm -f /etc/modules-load.d/k8s.conf /etc/sysctl.d/k8s.conf",
+			"sudo sysctl --system",
+		)
+	}
+
+	if err := i.Client.RunCommands(commands); err != nil {
+		return err
+	}
+	return i.ClearState()
+}
+
+// uninstallCommands returns commands that remove what InstallContainerRuntime and
+// InstallKubernetesComponents installed
+func (i *Installer) uninstallCommands() ([]string, error) {
+	if i.Config.IsImmutable() {
+		return []string{
+			"sudo systemctl disable --now kubelet 2>/dev/null || true",
+			"sudo rm -f /etc/systemd/system/kubelet.service",
+			"sudo rm -rf /etc/systemd/system/kubelet.service.d /opt/cni/bin",
+			"sudo rm -f " + binaryInstallDir + "/kubeadm " + binaryInstallDir + "/kubelet " + binaryInstallDir + "/kubectl " + binaryInstallDir + "/crictl",
+			// Back to the containerd configuration shipped with the OS
+			"sudo rm -f /etc/systemd/system/containerd.service.d/10-kubeforge.conf /etc/containerd/config.toml",
+			"sudo systemctl daemon-reload",
+			"sudo systemctl restart containerd",
+		}, nil
+	}
+
+	pm, err := i.Config.GetPackageManager()
+	if err != nil {
+		return nil, err
+	}
+	k8sPackages := []string{"kubelet", "kubeadm", "kubectl"}
+
+	// Package removal is best effort since an installation may have stopped halfway
+	commands := []string{
+		pm.Unhold(k8sPackages...) + " || true",
+		pm.Remove(append(k8sPackages, "kubernetes-cni", "cri-tools")...) + " || true",
+		pm.RemoveRepository(i.Config.KubernetesRepository().Name),
+	}
+
+	if repo := i.Config.ContainerdRepository(); repo != nil {
+		commands = append(commands, pm.Remove("containerd.io")+" || true", pm.RemoveRepository(repo.Name))
+	} else {
+		commands = append(commands, pm.Remove("containerd")+" || true")
+	}
+
+	return append(commands, "sudo rm -rf /etc/containerd /opt/cni/bin"), nil
+}
+
+// ClearState removes the installation state from the remote host and the local workstation
+func (i *Installer) ClearState() error {
+	if err := i.Client.RunCommands([]string{"sudo rm -f " + remoteStatePath}); err != nil {
+		return fmt.Errorf("failed to remove remote state: %v", err)
+	}
+
+	path, err := localStatePath(i.Config.Host)
+	if err != nil {
+		return fmt.Errorf("failed to locate local state: %v", err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove local state: %v", err)
+	}
+	return nil
+}
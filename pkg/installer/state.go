@@ -0,0 +1,116 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// remoteStatePath is where completed steps are recorded on the remote host
+const remoteStatePath = "/var/lib/kubeforge/state.json"
+
+// State records the installation steps completed on a host
+type State struct {
+	Host              string               `json:"host"`
+	KubernetesVersion string               `json:"kubernetesVersion"`
+	Completed         map[string]time.Time `json:"completed"`
+}
+
+// IsCompleted returns true if the step has been recorded as completed
+func (s *State) IsCompleted(step string) bool {
+	_, ok := s.Completed[step]
+	return ok
+}
+
+// MarkCompleted records the step as completed now
+func (s *State) MarkCompleted(step string) {
+	s.Completed[step] = time.Now().UTC()
+}
+
+// checkVersion returns an error if steps were completed for another Kubernetes
+// version. Resuming would skip installing the requested version on the host.
+func (s *State) checkVersion(version string) error {
+	if len(s.Completed) == 0 || s.KubernetesVersion == "" || s.KubernetesVersion == version {
+		return nil
+	}
+	return fmt.Errorf("installed with Kubernetes %s, not %s: use upgrade to change the version, or reset to start over",
+		s.KubernetesVersion, version)
+}
+
+// RecordVersion updates the Kubernetes version in the host's state after an upgrade.
+// A host without state has nothing to update.
+func (i *Installer) RecordVersion() error {
+	state, err := i.LoadState()
+	if err != nil {
+		return err
+	}
+	if len(state.Completed) == 0 {
+		return nil
+	}
+	state.KubernetesVersion = i.Config.KubernetesVersion
+	return i.SaveState(state)
+}
+
+// localStatePath returns the workstation copy of the state for the host
+func localStatePath(host string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kubeforge", "state", host+".json"), nil
+}
+
+// LoadState reads the installation state from the remote host. A host without state
+// starts from scratch, even when a local copy exists: the host may have been rebuilt
+// at the same address, and steps without a probe must not be skipped on it.
+func (i *Installer) LoadState() (*State, error) {
+	state := &State{
+		Host:              i.Config.Host,
+		KubernetesVersion: i.Config.KubernetesVersion,
+		Completed:         make(map[string]time.Time),
+	}
+
+	content, err := i.Client.RunCommand("sudo cat " + remoteStatePath + " 2>/dev/null || true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote state: %v", err)
+	}
+
+	if strings.TrimSpace(content) != "" {
+		if err := json.Unmarshal([]byte(content), state); err != nil {
+			return nil, fmt.Errorf("failed to parse installation state: %v", err)
+		}
+		if state.Completed == nil {
+			state.Completed = make(map[string]time.Time)
+		}
+	}
+
+	return state, nil
+}
+
+// SaveState writes the installation state to the remote host and the local workstation
+func (i *Installer) SaveState(state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode installation state: %v", err)
+	}
+
+	if err := i.Client.WriteFile(remoteStatePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write remote state: %v", err)
+	}
+
+	path, err := localStatePath(i.Config.Host)
+	if err != nil {
+		return fmt.Errorf("failed to locate local state: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create local state directory: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write local state: %v", err)
+	}
+
+	return nil
+}
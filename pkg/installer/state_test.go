@@ -0,0 +1,30 @@
+package installer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStateCheckVersion(t *testing.T) {
+	completed := map[string]time.Time{"prerequisites": time.Now()}
+
+	tests := []struct {
+		name    string
+		state   State
+		wantErr bool
+	}{
+		{"fresh host", State{}, false},
+		{"same version", State{KubernetesVersion: "1.33.4", Completed: completed}, false},
+		{"state without version", State{Completed: completed}, false},
+		{"other version without completed steps", State{KubernetesVersion: "1.32.9"}, false},
+		{"other version", State{KubernetesVersion: "1.32.9", Completed: completed}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.state.checkVersion("1.33.4"); (err != nil) != tt.wantErr {
+				t.Errorf("checkVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxKubeletSkew is how many minor releases a kubelet may lag behind the API server
+const maxKubeletSkew = 3
+
+// parseVersion splits a "1.33.4" style version into its components
+func parseVersion(version string) ([3]int, error) {
+	var parsed [3]int
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) != 3 {
+		return parsed, fmt.Errorf("invalid version '%s'", version)
+	}
+	for n, part := range parts {
+		// Pre-release and build suffixes such as "-rc.1" or "+k3s1" are ignored
+		if _, err := fmt.Sscanf(part, "%d", &parsed[n]); err != nil {
+			return parsed, fmt.Errorf("invalid version '%s'", version)
+		}
+	}
+	return parsed, nil
+}
+
+// CheckUpgrade validates an upgrade from the current cluster version to the target
+// against the Kubernetes version skew policy: no downgrades, one minor release at a
+// time, and no kubelet left more than three minor releases behind the API server.
+// The target may equal the current version so that a halted upgrade can be resumed.
+func CheckUpgrade(current, target string, nodes []Node) error {
+	from, err := parseVersion(current)
+	if err != nil {
+		return fmt.Errorf("failed to parse cluster version: %v", err)
+	}
+	to, err := parseVersion(target)
+	if err != nil {
+		return fmt.Errorf("failed to parse target version: %v", err)
+	}
+
+	switch {
+	case to[0] != from[0]:
+		return fmt.Errorf("cannot upgrade from v%s to v%s: major version changes are not supported", current, target)
+	case to[1] < from[1] || (to[1] == from[1] && to[2] < from[2]):
+		return fmt.Errorf("cannot upgrade from v%s to v%s: downgrades are not supported", current, target)
+	case to[1] > from[1]+1:
+		return fmt.Errorf("cannot upgrade from v%s to v%s: upgrade one minor release at a time, next to v%d.%d", current, target, from[0], from[1]+1)
+	}
+
+	for _, node := range nodes {
+		kubelet, err := parseVersion(node.KubeletVersion)
+		if err != nil {
+			return fmt.Errorf("failed to parse kubelet version of node %s: %v", node.Name, err)
+		}
+		if kubelet[1] < to[1]-maxKubeletSkew {
+			return fmt.Errorf("cannot upgrade to v%s: the kubelet of node %s is at %s, more than %d minor releases behind", target, node.Name, node.KubeletVersion, maxKubeletSkew)
+		}
+	}
+	return nil
+}
+
+// ClusterVersion returns the version of the API server, read from a control-plane host
+func (i *Installer) ClusterVersion() (string, error) {
+	output, err := i.Client.RunCommand(kubectl + " version -o json")
+	if err != nil {
+		return "", fmt.Errorf("failed to get cluster version: %v", err)
+	}
+
+	var version struct {
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal([]byte(output), &version); err != nil {
+		return "", fmt.Errorf("failed to parse cluster version: %v", err)
+	}
+	if version.ServerVersion.GitVersion == "" {
+		return "", fmt.Errorf("failed to get cluster version: no server version reported")
+	}
+	return strings.TrimPrefix(version.ServerVersion.GitVersion, "v"), nil
+}
+
+// upgradePackages returns commands that move the Kubernetes packages to the configured
+// version, switching the repository to its minor release
+func (i *Installer) upgradePackages(names ...string) ([]string, error) {
+	if i.Config.IsImmutable() {
+		var commands []string
+		for _, binary := range names {
+			url := fmt.Sprintf("https://dl.k8s.io/release/v%s/bin/linux/%s/%s", i.Config.KubernetesVersion, i.Config.Arch, binary)
+			dest := binaryInstallDir + "/" + binary
+			commands = append(commands, downloadVerified(url, dest+".new"), "sudo chmod +x "+dest+".new", "sudo mv -f "+dest+".new "+dest)
+		}
+		return commands, nil
+	}
+
+	pm, err := i.Config.GetPackageManager()
+	if err != nil {
+		return nil, err
+	}
+
+	commands := pm.AddRepository(*i.Config.KubernetesRepository())
+	commands = append(commands,
+		pm.Update(),
+		pm.Unhold(names...),
+		pm.InstallPinned(i.Config.KubernetesVersion, names...),
+		pm.Hold(names...),
+	)
+	return commands, nil
+}
+
+// UpgradeKubeadm installs kubeadm at the configured version
+func (i *Installer) UpgradeKubeadm() error {
+	commands, err := i.upgradePackages("kubeadm")
+	if err != nil {
+		return err
+	}
+	return i.Client.RunCommands(commands)
+}
+
+// PlanUpgrade checks with kubeadm that the cluster can be upgraded to the configured version
+func (i *Installer) PlanUpgrade() error {
+	command := "sudo kubeadm upgrade plan v" + i.Config.KubernetesVersion
+	i.Log.Command(command)
+	output, err := i.Client.RunCommand(command)
+	if err != nil {
+		return err
+	}
+	i.Log.Infof("%s", strings.TrimSpace(output))
+	return nil
+}
+
+// UpgradeNode upgrades the control plane components or kubelet configuration of the
+// host with kubeadm. The first control plane applies the new version to the cluster.
+func (i *Installer) UpgradeNode() error {
+	command := "sudo kubeadm upgrade node"
+	if i.Cluster.IsPrimary(i) {
+		command = "sudo kubeadm upgrade apply -y v" + i.Config.KubernetesVersion
+	}
+	return i.Client.RunCommands([]string{command})
+}
+
+// UpgradeKubelet installs kubelet and kubectl at the configured version and restarts the kubelet
+func (i *Installer) UpgradeKubelet() error {
+	commands, err := i.upgradePackages("kubelet", "kubectl")
+	if err != nil {
+		return err
+	}
+	commands = append(commands,
+		"sudo systemctl daemon-reload",
+		"sudo systemctl restart kubelet",
+	)
+	if err := i.Client.RunCommands(commands); err != nil {
+		return err
+	}
+	return i.RecordVersion()
+}
@@ -0,0 +1,49 @@
+package installer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckUpgrade(t *testing.T) {
+	nodes := func(versions ...string) []Node {
+		var list []Node
+		for n, version := range versions {
+			list = append(list, Node{Name: "node-" + string(rune('a'+n)), KubeletVersion: version})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name    string
+		current string
+		target  string
+		nodes   []Node
+		// err is a substring of the expected error; empty means the upgrade is allowed
+		err string
+	}{
+		{"patch release", "1.33.1", "1.33.4", nodes("v1.33.1", "v1.33.1"), ""},
+		{"next minor release", "1.33.4", "1.34.0", nodes("v1.33.4", "v1.32.9"), ""},
+		{"resumed upgrade", "1.34.0", "1.34.0", nodes("v1.34.0", "v1.33.4"), ""},
+		{"kubelet three minor releases behind", "1.33.4", "1.34.0", nodes("v1.33.4", "v1.31.2"), ""},
+		{"skipped minor release", "1.32.5", "1.34.0", nodes("v1.32.5"), "upgrade one minor release at a time, next to v1.33"},
+		{"downgrade of the minor release", "1.34.1", "1.33.9", nodes("v1.34.1"), "downgrades are not supported"},
+		{"downgrade of the patch release", "1.34.2", "1.34.1", nodes("v1.34.2"), "downgrades are not supported"},
+		{"major version change", "1.34.0", "2.0.0", nodes("v1.34.0"), "major version changes are not supported"},
+		{"kubelet four minor releases behind", "1.33.4", "1.34.0", nodes("v1.33.4", "v1.30.14"), "the kubelet of node node-b is at v1.30.14"},
+		{"invalid cluster version", "1.33", "1.34.0", nil, "failed to parse cluster version"},
+		{"invalid kubelet version", "1.33.4", "1.34.0", nodes("unknown"), "failed to parse kubelet version of node node-a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckUpgrade(tt.current, tt.target, tt.nodes)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("CheckUpgrade(%s, %s) error = %v, want none", tt.current, tt.target, err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("CheckUpgrade(%s, %s) error = %v, want %q", tt.current, tt.target, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultVerifyTimeout bounds each phase of the verification when no timeout is set
+const DefaultVerifyTimeout = 5 * time.Minute
+
+// smokeTestPod resolves the API server's service name through CoreDNS and exits
+const smokeTestPod = "kubeforge-smoke-test"
+
+// verifyNamespaces hold the control plane, DNS and CNI pods that must be healthy
+var verifyNamespaces = []string{"kube-system", "kube-flannel"}
+
+// VerifyOptions controls the post-install verification
+type VerifyOptions struct {
+	// Nodes is the minimum number of nodes the cluster must have
+	Nodes int
+	// Timeout bounds each phase of the verification; zero means DefaultVerifyTimeout
+	Timeout time.Duration
+}
+
+// Verify checks from a control-plane host that the cluster works: every node is
+// Ready and, with an external cloud provider, initialized by the cloud controller
+// manager, the system pods are running, and a smoke-test pod can be scheduled and
+// resolve a service name through CoreDNS. On failure a diagnostic dump of nodes,
+// pods, events and kubelet logs is logged. Cancelling the context stops the
+// verification.
+func (i *Installer) Verify(ctx context.Context, opts VerifyOptions) error {
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultVerifyTimeout
+	}
+
+	err := i.verify(ctx, opts)
+	if err != nil && ctx.Err() == nil {
+		i.Log.Infof("Diagnostics from %s:\n%s", i.Config.Host, i.diagnostics())
+	}
+	return err
+}
+
+// verify runs the verification phases in order
+func (i *Installer) verify(ctx context.Context, opts VerifyOptions) error {
+	err := poll(ctx, opts.Timeout, func() (bool, string, error) {
+		// The API server may still be settling, so errors are retried
+		nodes, err := i.GetNodes()
+		if err != nil {
+			return false, err.Error(), nil
+		}
+		var notReady []string
+		for _, node := range nodes {
+			if !node.Ready {
+				notReady = append(notReady, node.Name)
+			}
+		}
+		switch {
+		case len(notReady) > 0:
+			return false, "not ready: " + strings.Join(notReady, ", "), nil
+		case len(nodes) < opts.Nodes:
+			return false, fmt.Sprintf("%d of %d nodes registered", len(nodes), opts.Nodes), nil
+		}
+		return true, "", nil
+	})
+	if err != nil {
+		return fmt.Errorf("nodes did not become Ready: %v", err)
+	}
+
+	if i.externalCloudProvider() {
+		err = poll(ctx, opts.Timeout, func() (bool, string, error) {
+			nodes, err := i.GetNodes()
+			if err != nil {
+				return false, err.Error(), nil
+			}
+			var missing []string
+			for _, node := range nodes {
+				if node.ProviderID == "" {
+					missing = append(missing, node.Name)
+				}
+			}
+			return len(missing) == 0, "no provider ID: " + strings.Join(missing, ", "), nil
+		})
+		if err != nil {
+			return fmt.Errorf("cloud controller manager did not initialize the nodes: %v", err)
+		}
+	}
+
+	err = poll(ctx, opts.Timeout, func() (bool, string, error) {
+		unhealthy, err := i.unhealthyPods()
+		if err != nil {
+			return false, err.Error(), nil
+		}
+		return len(unhealthy) == 0, strings.Join(unhealthy, ", "), nil
+	})
+	if err != nil {
+		return fmt.Errorf("system pods are not running: %v", err)
+	}
+
+	return i.smokeTest(ctx, opts.Timeout)
+}
+
+// podList is the subset of "kubectl get pods -o json" that is read
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Status struct {
+			Phase             string `json:"phase"`
+			ContainerStatuses []struct {
+				Ready bool `json:"ready"`
+				State struct {
+					Waiting *struct {
+						Reason string `json:"reason"`
+					} `json:"waiting"`
+				} `json:"state"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// unhealthyPods lists the system pods that are neither running with all containers
+// ready nor completed, with the reason they are waiting
+func (i *Installer) unhealthyPods() ([]string, error) {
+	var unhealthy []string
+	for _, namespace := range verifyNamespaces {
+		output, err := i.Client.RunCommand(kubectl + " get pods -n " + namespace + " -o json")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %v", err)
+		}
+
+		var list podList
+		if err := json.Unmarshal([]byte(output), &list); err != nil {
+			return nil, fmt.Errorf("failed to parse pod list: %v", err)
+		}
+
+		for _, pod := range list.Items {
+			if pod.Status.Phase == "Succeeded" {
+				continue
+			}
+			reason := pod.Status.Phase
+			ready := pod.Status.Phase == "Running"
+			for _, container := range pod.Status.ContainerStatuses {
+				if !container.Ready {
+					ready = false
+				}
+				if container.State.Waiting != nil && container.State.Waiting.Reason != "" {
+					reason = container.State.Waiting.Reason
+				}
+			}
+			if !ready {
+				unhealthy = append(unhealthy, fmt.Sprintf("%s/%s (%s)", pod.Metadata.Namespace, pod.Metadata.Name, reason))
+			}
+		}
+	}
+	return unhealthy, nil
+}
+
+// smokeTest runs a pod that resolves the kubernetes service through CoreDNS and
+// deletes it again
+func (i *Installer) smokeTest(ctx context.Context, timeout time.Duration) error {
+	deletePod := kubectl + " delete pod " + smokeTestPod + " --ignore-not-found --wait=false"
+
+	// A pod left behind by an earlier run would make the creation fail
+	err := i.Client.RunCommands([]string{
+		kubectl + " delete pod " + smokeTestPod + " --ignore-not-found",
+		kubectl + " run " + smokeTestPod + " --image=busybox:1.36 --restart=Never" +
+			` --overrides='{"spec":{"tolerations":[{"operator":"Exists"}]}}'` +
+			" -- nslookup kubernetes.default.svc.cluster.local",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create smoke-test pod: %v", err)
+	}
+
+	err = poll(ctx, timeout, func() (bool, string, error) {
+		output, err := i.Client.RunCommand(kubectl + " get pod " + smokeTestPod + " -o jsonpath='{.status.phase}'")
+		if err != nil {
+			return false, err.Error(), nil
+		}
+		phase := strings.TrimSpace(output)
+		if phase == "Failed" {
+			logs, _, _ := i.Client.RunCommandWithOutput(kubectl + " logs " + smokeTestPod)
+			return false, "", fmt.Errorf("smoke-test pod failed: %s", strings.TrimSpace(logs))
+		}
+		return phase == "Succeeded", "phase " + phase, nil
+	})
+	if err != nil {
+		i.Client.RunCommand(deletePod)
+		return fmt.Errorf("smoke test failed: %v", err)
+	}
+
+	if err := i.Client.RunCommands([]string{deletePod}); err != nil {
+		return fmt.Errorf("failed to delete smoke-test pod: %v", err)
+	}
+	return nil
+}
+
+// diagnostics collects the cluster state for troubleshooting a failed verification.
+// Commands that fail contribute their error output.
+func (i *Installer) diagnostics() string {
+	commands := []string{
+		kubectl + " get nodes -o wide",
+		kubectl + " get pods -A -o wide",
+		kubectl + " get events -A --sort-by=.lastTimestamp | tail -n 30",
+		"sudo journalctl -u kubelet --no-pager -n 30",
+	}
+
+	var dump strings.Builder
+	for _, command := range commands {
+		stdout, stderr, _ := i.Client.RunCommandWithOutput(command)
+		fmt.Fprintf(&dump, "$ %s\n%s%s\n", command, stdout, stderr)
+	}
+	return strings.TrimRight(dump.String(), "\n")
+}
+
+// poll calls check every five seconds until it reports done, returns an error, or
+// the timeout passes. The last state reported by check is included in the timeout error.
+// Cancelling the context stops polling with the context's error.
+func poll(ctx context.Context, timeout time.Duration, check func() (done bool, state string, err error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		done, state, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s (%s)", timeout, state)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package kubeconfig reads, rewrites and merges kubectl configuration files
+package kubeconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a kubeconfig file. The cluster, context and user entries are kept as
+// maps so that fields this package does not know about survive a merge.
+type Config struct {
+	APIVersion     string                 `yaml:"apiVersion"`
+	Kind           string                 `yaml:"kind"`
+	Preferences    map[string]interface{} `yaml:"preferences"`
+	Clusters       []NamedCluster         `yaml:"clusters"`
+	Contexts       []NamedContext         `yaml:"contexts"`
+	Users          []NamedUser            `yaml:"users"`
+	CurrentContext string                 `yaml:"current-context"`
+	Extensions     []interface{}          `yaml:"extensions,omitempty"`
+}
+
+// NamedCluster is a cluster entry with its server and certificate authority
+type NamedCluster struct {
+	Name    string                 `yaml:"name"`
+	Cluster map[string]interface{} `yaml:"cluster"`
+}
+
+// NamedContext pairs a cluster with a user
+type NamedContext struct {
+	Name    string                 `yaml:"name"`
+	Context map[string]interface{} `yaml:"context"`
+}
+
+// NamedUser is a user entry with its credentials
+type NamedUser struct {
+	Name string                 `yaml:"name"`
+	User map[string]interface{} `yaml:"user"`
+}
+
+// New returns an empty kubeconfig
+func New() *Config {
+	return &Config{APIVersion: "v1", Kind: "Config"}
+}
+
+// Parse reads a kubeconfig in YAML or JSON
+func Parse(data []byte) (*Config, error) {
+	c := New()
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %v", err)
+	}
+	return c, nil
+}
+
+// Load reads a kubeconfig file, returning an empty kubeconfig if it does not exist
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %v", err)
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// DefaultPath returns the kubeconfig kubectl uses: the first file in $KUBECONFIG,
+// or ~/.kube/config
+func DefaultPath() string {
+	if paths := filepath.SplitList(os.Getenv("KUBECONFIG")); len(paths) > 0 && paths[0] != "" {
+		return paths[0]
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".kube", "config")
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// Rename gives the single cluster, user and context of a kubeadm admin kubeconfig
+// names derived from the cluster name and makes the context current
+func (c *Config) Rename(name string) error {
+	if len(c.Clusters) != 1 || len(c.Users) != 1 || len(c.Contexts) != 1 {
+		return fmt.Errorf("failed to rename kubeconfig: expected one cluster, user and context")
+	}
+
+	user := name + "-admin"
+	c.Clusters[0].Name = name
+	c.Users[0].Name = user
+	c.Contexts[0].Name = name
+	if c.Contexts[0].Context == nil {
+		c.Contexts[0].Context = map[string]interface{}{}
+	}
+	c.Contexts[0].Context["cluster"] = name
+	c.Contexts[0].Context["user"] = user
+	c.CurrentContext = name
+	return nil
+}
+
+// SetServer points every cluster at the API server URL
+func (c *Config) SetServer(server string) {
+	for n := range c.Clusters {
+		if c.Clusters[n].Cluster == nil {
+			c.Clusters[n].Cluster = map[string]interface{}{}
+		}
+		c.Clusters[n].Cluster["server"] = server
+	}
+}
+
+// SetTLSServerName makes every cluster verify the API server certificate for the
+// name instead of the server's host, for servers reached through a tunnel
+func (c *Config) SetTLSServerName(name string) {
+	for n := range c.Clusters {
+		if c.Clusters[n].Cluster == nil {
+			c.Clusters[n].Cluster = map[string]interface{}{}
+		}
+		c.Clusters[n].Cluster["tls-server-name"] = name
+	}
+}
+
+// Merge adds the clusters, contexts and users of other, replacing entries with the
+// same names, and switches to its current context
+func (c *Config) Merge(other *Config) {
+	for _, cluster := range other.Clusters {
+		c.Clusters = replaceOrAppend(c.Clusters, cluster, func(e NamedCluster) string { return e.Name })
+	}
+	for _, context := range other.Contexts {
+		c.Contexts = replaceOrAppend(c.Contexts, context, func(e NamedContext) string { return e.Name })
+	}
+	for _, user := range other.Users {
+		c.Users = replaceOrAppend(c.Users, user, func(e NamedUser) string { return e.Name })
+	}
+	if other.CurrentContext != "" {
+		c.CurrentContext = other.CurrentContext
+	}
+}
+
+// replaceOrAppend replaces the entry with the same name or appends it
+func replaceOrAppend[T any](entries []T, entry T, name func(T) string) []T {
+	for n := range entries {
+		if name(entries[n]) == name(entry) {
+			entries[n] = entry
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
+
+// Marshal returns the kubeconfig as YAML indented like kubectl writes it
+func (c *Config) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, fmt.Errorf("failed to render kubeconfig: %v", err)
+	}
+	encoder.Close()
+	return buf.Bytes(), nil
+}
+
+// WriteFile writes the kubeconfig readable only by the owner. An existing file is
+// first copied to a timestamped backup, whose path is returned.
+func WriteFile(path string, c *Config) (string, error) {
+	data, err := c.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create kubeconfig directory: %v", err)
+	}
+
+	backup := ""
+	if previous, err := os.ReadFile(path); err == nil {
+		backup = path + "." + time.Now().Format("20060102-150405") + ".bak"
+		if err := os.WriteFile(backup, previous, 0600); err != nil {
+			return "", fmt.Errorf("failed to back up kubeconfig: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read kubeconfig: %v", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return backup, fmt.Errorf("failed to write kubeconfig: %v", err)
+	}
+	return backup, nil
+}
+
+// MergeFile merges the kubeconfig data into the file at path, creating it if needed,
+// and returns the path of the backup of the previous file
+func MergeFile(path string, data []byte) (string, error) {
+	existing, err := Load(path)
+	if err != nil {
+		return "", err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return "", err
+	}
+	existing.Merge(c)
+	return WriteFile(path, existing)
+}
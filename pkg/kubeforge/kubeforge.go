@@ -0,0 +1,374 @@
+// Package kubeforge is the Go API for installing Kubernetes clusters over SSH.
+//
+//	result, err := kubeforge.Install(ctx, kubeforge.Spec{
+//		Provider: "aws",
+//		SSH:      config.SSHConfig{User: "ubuntu", PrivateKey: "/home/me/.ssh/id_rsa"},
+//		Hosts:    []config.HostConfig{{Address: "203.0.113.10"}},
+//	})
+//
+// Install prints nothing unless WithOutput is given; progress is available as
+// events through WithEvents or WithEventHandler.
+package kubeforge
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/installer"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/kubeconfig"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+)
+
+// Spec describes the cluster to install. It has the same shape as the JSON file
+// accepted by the CLI's -config flag.
+type Spec = config.FileConfig
+
+// Event is a progress notification emitted during installation
+type Event = events.Event
+
+// Result describes an installed cluster
+type Result struct {
+	// Hosts lists every node with its detected platform
+	Hosts []HostInfo
+	// Kubeconfig is the cluster's admin kubeconfig, pointing at the first control
+	// plane's address and named after the cluster
+	Kubeconfig []byte
+	// JoinCommand joins further nodes to the cluster
+	JoinCommand string
+	// Preflight lists the failed preflight checks, including warnings and ignored errors
+	Preflight []installer.PreflightResult
+	// Steps records the outcome and duration of every step on every host
+	Steps []installer.StepResult
+	// Warnings collects the warnings emitted during installation
+	Warnings []string
+}
+
+// HostInfo describes one installed node
+type HostInfo struct {
+	Address      string `json:"address"`
+	Role         string `json:"role"`
+	User         string `json:"user"`
+	Distribution string `json:"distribution"`
+	Version      string `json:"version"`
+	Arch         string `json:"arch"`
+}
+
+// Duration returns the total time spent running steps
+func (r *Result) Duration() time.Duration {
+	var total time.Duration
+	for _, step := range r.Steps {
+		total += step.Duration
+	}
+	return total
+}
+
+// host is a node to install with its configuration and role
+type host struct {
+	cfg  *config.Config
+	role string
+}
+
+// Install connects to every host in the spec and installs Kubernetes. The returned
+// result is filled in as far as the installation got, also when an error is returned.
+func Install(ctx context.Context, spec Spec, opts ...Option) (*Result, error) {
+	return run(ctx, spec, newOptions(opts), false)
+}
+
+// Join adds the hosts of the spec to a running cluster. The first control-plane host
+// is the cluster's existing control plane and is left unchanged; every other host is
+// installed and joined with its role.
+func Join(ctx context.Context, spec Spec, opts ...Option) (*Result, error) {
+	return run(ctx, spec, newOptions(opts), true)
+}
+
+// run installs the hosts of the spec, joining them to an existing control plane
+// when join is set
+func run(ctx context.Context, spec Spec, o *options, join bool) (*Result, error) {
+	result := &Result{}
+
+	hosts, err := newHosts(spec)
+	if err != nil {
+		return result, err
+	}
+	registry, err := newRegistry(spec, o)
+	if err != nil {
+		return result, err
+	}
+
+	logger := newResultLogger(o, result)
+
+	engine := installer.NewEngine(registry)
+	engine.Parallel = o.parallel
+
+	primary := primaryHost(hosts)
+	if join {
+		existing, err := connect(ctx, primary, logger, false)
+		if err != nil {
+			return result, err
+		}
+		defer existing.Client.Close()
+		engine.Cluster.ControlPlane = existing
+	} else if countRole(hosts, installer.RoleControlPlane) > 1 {
+		// Additional control planes need a shared endpoint; the first one provides it
+		engine.Cluster.Endpoint = primary.cfg.Host + ":" + installer.APIServerPort
+	}
+
+	// The engine, the verification and the cluster access use the sessions until the end
+	defer closeHosts(engine)
+	for _, h := range hosts {
+		if join && h == primary {
+			continue
+		}
+
+		i, err := connect(ctx, h, logger, true)
+		if err != nil {
+			return result, err
+		}
+
+		result.Hosts = append(result.Hosts, HostInfo{
+			Address:      h.cfg.Host,
+			Role:         h.role,
+			User:         h.cfg.User,
+			Distribution: string(h.cfg.Distribution),
+			Version:      h.cfg.DistributionVersion,
+			Arch:         string(h.cfg.Arch),
+		})
+		engine.AddHost(h.role, i)
+	}
+
+	// Check every host before changing any; a single step is run as asked
+	if o.run.OnlyStep == "" {
+		result.Preflight, err = engine.Preflight(ctx, installer.DefaultPreflightChecks(), o.ignorePreflight)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// Run installation steps, skipping those already completed on each host
+	result.Steps, err = engine.Run(ctx, o.run)
+	if err != nil {
+		return result, fmt.Errorf("installation failed: %v", err)
+	}
+
+	controlPlane := engine.Cluster.ControlPlane
+	if !o.skipVerify {
+		// Every installed host plus the existing control plane when joining
+		nodes := len(engine.Hosts)
+		if join {
+			nodes++
+		}
+		err := operation(ctx, result, controlPlane, "verify", "Verifying the cluster", func() error {
+			return controlPlane.Verify(ctx, installer.VerifyOptions{Nodes: nodes, Timeout: o.verifyTimeout})
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if join {
+		result.JoinCommand, _ = engine.Cluster.JoinCommand()
+		return result, nil
+	}
+
+	collectClusterAccess(controlPlane, spec, o, result)
+	controlPlane.DisplayCloudProviderInfo()
+
+	return result, nil
+}
+
+// closeHosts closes the SSH session of every host added to the engine
+func closeHosts(engine *installer.Engine) {
+	for _, h := range engine.Hosts {
+		h.Installer.Client.Close()
+	}
+}
+
+// connect opens an SSH session to the host and returns its installer, detecting the
+// remote platform when detect is set. The caller closes the installer's client.
+func connect(ctx context.Context, h *host, logger *events.Logger, detect bool) (*installer.Installer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sshClient, err := ssh.NewClient(h.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", h.cfg.Host, err)
+	}
+
+	log := logger.ForHost(h.cfg.Host)
+	log.Infof("Connected to %s successfully", h.cfg.Host)
+
+	i := installer.NewInstaller(sshClient, h.cfg)
+	i.SetLogger(log)
+	i.Role = h.role
+
+	if detect {
+		// Detect the remote operating system and architecture
+		if err := i.DetectPlatform(); err != nil {
+			sshClient.Close()
+			return nil, fmt.Errorf("failed to detect platform on %s: %v", h.cfg.Host, err)
+		}
+		log.Infof("%s (%s): %s %s (%s)", h.cfg.Host, h.role, h.cfg.Distribution, h.cfg.DistributionVersion, h.cfg.Arch)
+	}
+
+	return i, nil
+}
+
+// collectClusterAccess fetches the admin kubeconfig and join command from the control
+// plane. Failures are reported as warnings since the cluster itself is installed.
+func collectClusterAccess(i *installer.Installer, spec Spec, o *options, result *Result) {
+	c, err := clusterKubeconfig(i, spec, apiServerURL(i, o))
+	if err == nil {
+		result.Kubeconfig, err = c.Marshal()
+	}
+	if err != nil {
+		i.Log.Warnf("%v", err)
+		return
+	}
+
+	// The join command is only printed by kubeadm init, so create one when resuming
+	result.JoinCommand, err = i.Cluster.JoinCommand()
+	if err != nil {
+		i.Log.Warnf("Could not create join command: %v", err)
+	}
+}
+
+// clusterKubeconfig fetches the admin kubeconfig from a control plane, names its
+// cluster, context and user after the cluster and points it at the server
+func clusterKubeconfig(i *installer.Installer, spec Spec, server string) (*kubeconfig.Config, error) {
+	data, err := i.AdminKubeconfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := kubeconfig.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Rename(firstNonEmpty(spec.ClusterName, i.Config.Host)); err != nil {
+		return nil, err
+	}
+	c.SetServer(server)
+	return c, nil
+}
+
+// apiServerURL returns the API server the workstation reaches: the server given with
+// WithKubeconfigServer or the control plane's address
+func apiServerURL(i *installer.Installer, o *options) string {
+	return firstNonEmpty(o.kubeconfigServer, "https://"+net.JoinHostPort(i.Config.Host, installer.APIServerPort))
+}
+
+// primaryHost returns the first control-plane host
+func primaryHost(hosts []*host) *host {
+	for _, h := range hosts {
+		if h.role == installer.RoleControlPlane {
+			return h
+		}
+	}
+	return hosts[0]
+}
+
+// countRole returns the number of hosts with the role
+func countRole(hosts []*host, role string) int {
+	count := 0
+	for _, h := range hosts {
+		if h.role == role {
+			count++
+		}
+	}
+	return count
+}
+
+// newHosts builds the configuration of every host in the spec. The first host
+// defaults to the control plane and the rest to workers.
+func newHosts(spec Spec) ([]*host, error) {
+	if len(spec.Hosts) == 0 {
+		return nil, fmt.Errorf("at least one host is required")
+	}
+
+	// The provider is detected on each host unless the spec names one
+	provider := spec.Provider
+	if provider == "" {
+		provider = string(config.Auto)
+	}
+
+	var hosts []*host
+	for n, hc := range spec.Hosts {
+		role := hc.Role
+		if role == "" && n == 0 {
+			role = installer.RoleControlPlane
+		} else if role == "" {
+			role = installer.RoleWorker
+		}
+		if role != installer.RoleControlPlane && role != installer.RoleWorker {
+			return nil, fmt.Errorf("host %s: invalid role '%s': use %s or %s", hc.Address, role, installer.RoleControlPlane, installer.RoleWorker)
+		}
+
+		cfg, err := config.NewConfig(hc.Address,
+			firstNonEmpty(hc.Port, spec.SSH.Port, "22"),
+			firstNonEmpty(hc.User, spec.SSH.User),
+			spec.SSH.PrivateKey,
+			spec.SSH.Password,
+			provider,
+			spec.Distribution)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Provider == config.Oracle {
+			// Checked before connecting, since nothing on the host can fill them in
+			if err := spec.Oracle.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		cfg.ClusterName = spec.ClusterName
+		cfg.Azure = spec.Azure
+		cfg.Oracle = spec.Oracle
+		cfg.BareMetal = spec.BareMetal
+		for _, jump := range strings.Split(firstNonEmpty(hc.JumpHost, spec.SSH.JumpHost), ",") {
+			if jump = strings.TrimSpace(jump); jump != "" {
+				cfg.JumpHosts = append(cfg.JumpHosts, jump)
+			}
+		}
+		if spec.KubernetesVersion != "" {
+			if err := cfg.SetKubernetesVersion(spec.KubernetesVersion); err != nil {
+				return nil, err
+			}
+		}
+		hosts = append(hosts, &host{cfg: cfg, role: role})
+	}
+
+	for _, h := range hosts {
+		if h.role == installer.RoleControlPlane {
+			return hosts, nil
+		}
+	}
+	return nil, fmt.Errorf("at least one host must have the %s role", installer.RoleControlPlane)
+}
+
+// newRegistry returns the registry to run with the spec's custom steps added. They
+// are added to a copy, so a registry passed with WithRegistry can be reused.
+func newRegistry(spec Spec, o *options) (*installer.Registry, error) {
+	registry := installer.DefaultRegistry()
+	if o.registry != nil {
+		registry = o.registry.Clone()
+	}
+	if err := installer.RegisterCustomSteps(registry, spec.Steps); err != nil {
+		return nil, fmt.Errorf("failed to load custom steps: %v", err)
+	}
+	return registry, nil
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,342 @@
+package kubeforge
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/installer"
+)
+
+// Status describes a running cluster
+type Status struct {
+	// Nodes are the cluster nodes reported by the first control plane
+	Nodes []installer.Node `json:"nodes"`
+	// Hosts are the installation states of the hosts in the spec
+	Hosts []HostStatus `json:"hosts"`
+}
+
+// HostStatus is the installation state recorded on one host
+type HostStatus struct {
+	Address           string   `json:"address"`
+	Role              string   `json:"role"`
+	KubernetesVersion string   `json:"kubernetesVersion,omitempty"`
+	Completed         []string `json:"completed"`
+	Error             string   `json:"error,omitempty"`
+}
+
+// Reset reverts the installation on the hosts of the spec. Nodes that belong to the
+// cluster are drained before and deleted after their reset through the first control
+// plane, which is reset last. With WithKeepControlPlane the first control plane is
+// only used to drain and delete the other hosts.
+func Reset(ctx context.Context, spec Spec, opts ...Option) (*Result, error) {
+	o := newOptions(opts)
+	result := &Result{}
+	logger := newResultLogger(o, result)
+
+	hosts, err := newHosts(spec)
+	if err != nil {
+		return result, err
+	}
+
+	primary := primaryHost(hosts)
+	controlPlane, err := connect(ctx, primary, logger, !o.keepControlPlane)
+	if err != nil {
+		return result, err
+	}
+	defer controlPlane.Client.Close()
+
+	// A cluster that is already gone is not an error; nodes are then not drained
+	nodes, err := controlPlane.GetNodes()
+	if err != nil {
+		controlPlane.Log.Warnf("Cluster not reachable through %s, nodes will not be drained: %v", primary.cfg.Host, err)
+	}
+
+	// Workers go before the control planes they depend on, the first control plane last
+	var ordered []*host
+	for _, role := range []string{installer.RoleWorker, installer.RoleControlPlane} {
+		for _, h := range hosts {
+			if h.role == role && h != primary {
+				ordered = append(ordered, h)
+			}
+		}
+	}
+
+	for _, h := range ordered {
+		i, err := connect(ctx, h, logger, true)
+		if err != nil {
+			return result, err
+		}
+		err = resetNode(ctx, result, i, controlPlane, nodes, o.reset)
+		i.Client.Close()
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if o.keepControlPlane {
+		return result, nil
+	}
+	err = operation(ctx, result, controlPlane, "reset", "Resetting node", func() error {
+		return controlPlane.Reset(o.reset)
+	})
+	return result, err
+}
+
+// resetNode drains a host through the control plane, resets it and deletes its node
+func resetNode(ctx context.Context, result *Result, i, controlPlane *installer.Installer, nodes []installer.Node, opts installer.ResetOptions) error {
+	name, err := i.FindNode(nodes)
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		err := operation(ctx, result, i, "drain", "Draining node "+name, func() error {
+			return controlPlane.DrainNode(name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = operation(ctx, result, i, "reset", "Resetting node", func() error {
+		return i.Reset(opts)
+	})
+	if err != nil || name == "" {
+		return err
+	}
+
+	return operation(ctx, result, i, "delete-node", "Deleting node "+name, func() error {
+		return controlPlane.DeleteNode(name)
+	})
+}
+
+// nodeReadyTimeout is how long an upgraded node has to become Ready before the upgrade halts
+const nodeReadyTimeout = 5 * time.Minute
+
+// Upgrade moves the cluster to the spec's Kubernetes version after validating it
+// against the version skew policy. The first control plane applies the new version
+// with kubeadm, then every node is upgraded one at a time: cordoned and drained, its
+// kubelet and kubectl upgraded and restarted, and uncordoned. The upgrade halts on
+// the first node that does not return Ready.
+func Upgrade(ctx context.Context, spec Spec, opts ...Option) (*Result, error) {
+	o := newOptions(opts)
+	result := &Result{}
+	logger := newResultLogger(o, result)
+
+	if spec.KubernetesVersion == "" {
+		return result, fmt.Errorf("a target Kubernetes version is required")
+	}
+	hosts, err := newHosts(spec)
+	if err != nil {
+		return result, err
+	}
+
+	primary := primaryHost(hosts)
+	controlPlane, err := connect(ctx, primary, logger, true)
+	if err != nil {
+		return result, err
+	}
+	defer controlPlane.Client.Close()
+	controlPlane.Cluster = &installer.Cluster{ControlPlane: controlPlane}
+
+	nodes, err := controlPlane.GetNodes()
+	if err != nil {
+		return result, err
+	}
+	current, err := controlPlane.ClusterVersion()
+	if err != nil {
+		return result, err
+	}
+	if err := installer.CheckUpgrade(current, controlPlane.Config.KubernetesVersion, nodes); err != nil {
+		return result, err
+	}
+	controlPlane.Log.Infof("Upgrading the cluster from v%s to v%s", current, controlPlane.Config.KubernetesVersion)
+
+	if err := upgradeHost(ctx, result, controlPlane, controlPlane, nodes); err != nil {
+		return result, err
+	}
+
+	// The other control planes go before the workers
+	for _, role := range []string{installer.RoleControlPlane, installer.RoleWorker} {
+		for _, h := range hosts {
+			if h.role != role || h == primary {
+				continue
+			}
+			i, err := connect(ctx, h, logger, true)
+			if err != nil {
+				return result, err
+			}
+			i.Cluster = controlPlane.Cluster
+
+			err = upgradeHost(ctx, result, i, controlPlane, nodes)
+			i.Client.Close()
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// upgradeHost upgrades kubeadm and the node with it, then cordons and drains the node
+// through the control plane, upgrades its kubelet and uncordons it once it is Ready
+func upgradeHost(ctx context.Context, result *Result, i, controlPlane *installer.Installer, nodes []installer.Node) error {
+	name, err := i.FindNode(nodes)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("%s is not a node of the cluster", i.Config.Host)
+	}
+	version := i.Config.KubernetesVersion
+
+	if err := operation(ctx, result, i, "upgrade-kubeadm", "Upgrading kubeadm", i.UpgradeKubeadm); err != nil {
+		return err
+	}
+	if i == controlPlane {
+		if err := operation(ctx, result, i, "upgrade-plan", "Checking the upgrade with kubeadm", i.PlanUpgrade); err != nil {
+			return err
+		}
+	}
+	if err := operation(ctx, result, i, "upgrade-node", "Upgrading node with kubeadm", i.UpgradeNode); err != nil {
+		return err
+	}
+
+	err = operation(ctx, result, i, "drain", "Draining node "+name, func() error {
+		if err := controlPlane.CordonNode(name); err != nil {
+			return err
+		}
+		return controlPlane.DrainNode(name)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := operation(ctx, result, i, "upgrade-kubelet", "Upgrading kubelet and kubectl", i.UpgradeKubelet); err != nil {
+		return err
+	}
+
+	// A node that does not come back stays cordoned for inspection
+	err = operation(ctx, result, i, "wait-ready", "Waiting for node "+name+" to become Ready", func() error {
+		return controlPlane.WaitForNode(ctx, name, version, nodeReadyTimeout)
+	})
+	if err != nil {
+		return err
+	}
+
+	return operation(ctx, result, i, "uncordon", "Uncordoning node "+name, func() error {
+		return controlPlane.UncordonNode(name)
+	})
+}
+
+// GetStatus reports the cluster nodes and the installation state of every host.
+// Hosts that cannot be reached are reported with an error; only the first control
+// plane is required.
+func GetStatus(ctx context.Context, spec Spec, opts ...Option) (*Status, error) {
+	o := newOptions(opts)
+	logger := events.NewLogger(o.handlers...)
+
+	hosts, err := newHosts(spec)
+	if err != nil {
+		return nil, err
+	}
+	primary := primaryHost(hosts)
+
+	status := &Status{}
+	for _, h := range hosts {
+		hostStatus := HostStatus{Address: h.cfg.Host, Role: h.role, Completed: []string{}}
+
+		i, err := connect(ctx, h, logger, false)
+		if err != nil {
+			if h == primary {
+				return nil, err
+			}
+			hostStatus.Error = err.Error()
+			status.Hosts = append(status.Hosts, hostStatus)
+			continue
+		}
+
+		if state, err := i.LoadState(); err != nil {
+			hostStatus.Error = err.Error()
+		} else if len(state.Completed) > 0 {
+			hostStatus.KubernetesVersion = state.KubernetesVersion
+			for step := range state.Completed {
+				hostStatus.Completed = append(hostStatus.Completed, step)
+			}
+			sort.Strings(hostStatus.Completed)
+		}
+
+		if h == primary {
+			status.Nodes, err = i.GetNodes()
+		}
+		i.Client.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		status.Hosts = append(status.Hosts, hostStatus)
+	}
+
+	return status, nil
+}
+
+// Kubeconfig returns the admin kubeconfig of the cluster from its first control plane,
+// named after the cluster and pointing at the control plane's address or the server
+// given with WithKubeconfigServer
+func Kubeconfig(ctx context.Context, spec Spec, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+
+	hosts, err := newHosts(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := connect(ctx, primaryHost(hosts), events.NewLogger(o.handlers...), false)
+	if err != nil {
+		return nil, err
+	}
+	defer i.Client.Close()
+
+	c, err := clusterKubeconfig(i, spec, apiServerURL(i, o))
+	if err != nil {
+		return nil, err
+	}
+	return c.Marshal()
+}
+
+// newResultLogger creates a logger for the options that also collects warnings into the result
+func newResultLogger(o *options, result *Result) *events.Logger {
+	logger := events.NewLogger(o.handlers...)
+	logger.AddHandler(func(e Event) {
+		if e.Type == events.Warning {
+			result.Warnings = append(result.Warnings, e.Message)
+		}
+	})
+	return logger
+}
+
+// operation runs an action on a host and records it in the result like an engine step
+func operation(ctx context.Context, result *Result, i *installer.Installer, name, description string, action func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	i.Log.Emit(events.Event{Type: events.StepStarted, Step: name, Message: description})
+
+	err := action()
+	stepResult := installer.StepResult{Step: name, Host: i.Config.Host, Attempts: 1, Duration: time.Since(start), Err: err}
+	result.Steps = append(result.Steps, stepResult)
+
+	if err != nil {
+		i.Log.Emit(events.Event{Type: events.StepFailed, Step: name, Message: description, Duration: stepResult.Duration, Error: err.Error()})
+		return fmt.Errorf("failed to %s on %s: %v", strings.ToLower(description), i.Config.Host, err)
+	}
+	i.Log.Emit(events.Event{Type: events.StepFinished, Step: name, Message: description, Duration: stepResult.Duration})
+	return nil
+}
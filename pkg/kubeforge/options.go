@@ -0,0 +1,160 @@
+package kubeforge
+
+import (
+	"io"
+	"time"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/installer"
+)
+
+// Option customizes Install and Plan
+type Option func(*options)
+
+// options holds the settings applied by Option values
+type options struct {
+	handlers []events.Handler
+	run      installer.RunOptions
+	parallel bool
+	registry *installer.Registry
+	detect   bool
+	arch     config.Architecture
+
+	ignorePreflight []string
+	skipVerify      bool
+	verifyTimeout   time.Duration
+
+	kubeconfigServer string
+
+	reset            installer.ResetOptions
+	keepControlPlane bool
+}
+
+// newOptions applies the options over the defaults
+func newOptions(opts []Option) *options {
+	o := &options{
+		arch: config.AMD64,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithOutput prints human-readable progress, commands and provider information to w.
+// Nothing is printed without it.
+func WithOutput(w io.Writer) Option {
+	return WithEventHandler(events.HumanHandler(w))
+}
+
+// WithEvents sends every progress event to the channel. Sends block, so the
+// caller must keep receiving until Install returns; the channel is not closed.
+func WithEvents(ch chan<- Event) Option {
+	return WithEventHandler(func(e Event) {
+		ch <- e
+	})
+}
+
+// WithEventHandler calls the handler for every progress event
+func WithEventHandler(h func(Event)) Option {
+	return func(o *options) {
+		o.handlers = append(o.handlers, h)
+	}
+}
+
+// WithFromStep resumes from the named step, re-running it and every later step
+func WithFromStep(step string) Option {
+	return func(o *options) {
+		o.run.FromStep = step
+	}
+}
+
+// WithOnlyStep runs only the named step, even if it was completed before
+func WithOnlyStep(step string) Option {
+	return func(o *options) {
+		o.run.OnlyStep = step
+	}
+}
+
+// WithParallel runs each step on all of its hosts at once
+func WithParallel(parallel bool) Option {
+	return func(o *options) {
+		o.parallel = parallel
+	}
+}
+
+// WithRegistry replaces the built-in steps. Custom steps from the spec are added to it.
+func WithRegistry(r *installer.Registry) Option {
+	return func(o *options) {
+		o.registry = r
+	}
+}
+
+// WithDetect makes Plan connect read-only to detect each host's platform
+func WithDetect(detect bool) Option {
+	return func(o *options) {
+		o.detect = detect
+	}
+}
+
+// WithArch sets the architecture Plan assumes when the platform is not detected
+func WithArch(arch config.Architecture) Option {
+	return func(o *options) {
+		o.arch = arch
+	}
+}
+
+// WithIgnorePreflight turns failures of the named preflight checks into warnings.
+// The name "all" ignores every check.
+func WithIgnorePreflight(checks ...string) Option {
+	return func(o *options) {
+		o.ignorePreflight = append(o.ignorePreflight, checks...)
+	}
+}
+
+// WithSkipVerify skips waiting for the nodes, system pods and a smoke-test pod after
+// installing
+func WithSkipVerify(skip bool) Option {
+	return func(o *options) {
+		o.skipVerify = skip
+	}
+}
+
+// WithVerifyTimeout bounds each phase of the post-install verification
+func WithVerifyTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.verifyTimeout = timeout
+	}
+}
+
+// WithKubeconfigServer sets the API server URL written into kubeconfig files, such as
+// a load balancer or VIP in front of the control planes
+func WithKubeconfigServer(server string) Option {
+	return func(o *options) {
+		o.kubeconfigServer = server
+	}
+}
+
+// WithUninstall makes Reset remove the Kubernetes and container runtime packages and
+// their repositories
+func WithUninstall(uninstall bool) Option {
+	return func(o *options) {
+		o.reset.Uninstall = uninstall
+	}
+}
+
+// WithRevertSystem makes Reset remove the kernel module and sysctl settings
+func WithRevertSystem(revert bool) Option {
+	return func(o *options) {
+		o.reset.RevertSystem = revert
+	}
+}
+
+// WithKeepControlPlane makes Reset leave the first control plane of the spec running
+// and only use it to drain and delete the other hosts
+func WithKeepControlPlane(keep bool) Option {
+	return func(o *options) {
+		o.keepControlPlane = keep
+	}
+}
@@ -0,0 +1,71 @@
+package kubeforge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/installer"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+)
+
+// Plan returns every remote command and file write Install would perform, without
+// changing the hosts. With WithDetect the platform and cloud provider are detected
+// over a read-only SSH session; otherwise the spec must name the distribution and
+// provider, and WithArch sets the architecture.
+func Plan(ctx context.Context, spec Spec, opts ...Option) ([]*installer.Plan, error) {
+	o := newOptions(opts)
+
+	hosts, err := newHosts(spec)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := newRegistry(spec, o)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := events.NewLogger(o.handlers...)
+
+	var plans []*installer.Plan
+	for _, h := range hosts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		cfg := h.cfg
+		if o.detect {
+			sshClient, err := ssh.NewClient(cfg)
+			if err != nil {
+				return nil, err
+			}
+			detector := installer.NewInstaller(sshClient, cfg)
+			detector.SetLogger(logger.ForHost(cfg.Host))
+			err = detector.DetectPlatform()
+			sshClient.Close()
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			if cfg.Distribution == "" {
+				return nil, fmt.Errorf("a distribution is required for a plan without platform detection")
+			}
+			if cfg.Provider == config.Auto {
+				return nil, fmt.Errorf("a cloud provider is required for a plan without platform detection")
+			}
+			if err := config.ValidatePlatform(cfg.Distribution, "", o.arch); err != nil {
+				return nil, err
+			}
+			cfg.Arch = o.arch
+		}
+
+		plan, err := installer.BuildPlan(registry, cfg, h.role)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
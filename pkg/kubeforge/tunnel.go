@@ -0,0 +1,77 @@
+package kubeforge
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/installer"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+)
+
+// apiServerCertName is always among the names of a kubeadm API server certificate
+const apiServerCertName = "kubernetes"
+
+// Tunnel is an SSH tunnel from the workstation to the API server of the first
+// control plane, for clusters whose API server is only reachable privately
+type Tunnel struct {
+	// Address is the local end of the tunnel
+	Address string
+	// Kubeconfig is the admin kubeconfig pointing at the local end
+	Kubeconfig []byte
+
+	tunnel *ssh.Tunnel
+	client *ssh.Client
+}
+
+// OpenTunnel connects to the first control plane, through the spec's jump hosts if
+// any, and forwards the local address to its API server. A port of 0 picks a free
+// port. The tunnel stays open until Close is called.
+func OpenTunnel(ctx context.Context, spec Spec, listen string, opts ...Option) (*Tunnel, error) {
+	o := newOptions(opts)
+
+	hosts, err := newHosts(spec)
+	if err != nil {
+		return nil, err
+	}
+	h := primaryHost(hosts)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	client, err := ssh.NewClient(h.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", h.cfg.Host, err)
+	}
+	i := installer.NewInstaller(client, h.cfg)
+	i.SetLogger(events.NewLogger(o.handlers...).ForHost(h.cfg.Host))
+
+	// The API server listens on every interface of the control plane
+	forward, err := client.Forward(listen, net.JoinHostPort("127.0.0.1", installer.APIServerPort))
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	t := &Tunnel{Address: forward.Addr(), tunnel: forward, client: client}
+
+	c, err := clusterKubeconfig(i, spec, "https://"+t.Address)
+	if err == nil {
+		// The certificate is not issued for the local address
+		c.SetTLSServerName(apiServerCertName)
+		t.Kubeconfig, err = c.Marshal()
+	}
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	i.Log.Infof("Forwarding %s to the API server of %s", t.Address, h.cfg.Host)
+	return t, nil
+}
+
+// Close stops the tunnel and disconnects from the control plane
+func (t *Tunnel) Close() error {
+	t.tunnel.Close()
+	return t.client.Close()
+}
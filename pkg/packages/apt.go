@@ -0,0 +1,80 @@
+package packages
+
+import (
+	"fmt"
+	"strings"
+)
+
+// APT implements the Manager interface for Debian-based distributions
+type APT struct{}
+
+// NewAPT creates a new apt package manager
+func NewAPT() *APT {
+	return &APT{}
+}
+
+// Name returns the package manager binary name
+func (a *APT) Name() string {
+	return "apt"
+}
+
+// Update refreshes the package metadata
+func (a *APT) Update() string {
+	return "sudo apt-get update"
+}
+
+// Install installs the latest available version of the packages
+func (a *APT) Install(names ...string) string {
+	return "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y " + strings.Join(names, " ")
+}
+
+// InstallPinned installs the packages at the given upstream version, matching any Debian revision
+func (a *APT) InstallPinned(version string, names ...string) string {
+	pinned := make([]string, len(names))
+	for i, name := range names {
+		pinned[i] = fmt.Sprintf("'%s=%s-*'", name, version)
+	}
+	return "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y --allow-change-held-packages " + strings.Join(pinned, " ")
+}
+
+// Hold prevents the packages from being upgraded
+func (a *APT) Hold(names ...string) string {
+	return "sudo apt-mark hold " + strings.Join(names, " ")
+}
+
+// Unhold allows previously held packages to be upgraded again
+func (a *APT) Unhold(names ...string) string {
+	return "sudo apt-mark unhold " + strings.Join(names, " ")
+}
+
+// Remove uninstalls the packages
+func (a *APT) Remove(names ...string) string {
+	return "sudo DEBIAN_FRONTEND=noninteractive apt-get purge -y --allow-change-held-packages " + strings.Join(names, " ")
+}
+
+// AddRepository configures an apt source with a dedicated keyring
+func (a *APT) AddRepository(repo Repository) []string {
+	keyring := "/etc/apt/keyrings/" + repo.Name + ".gpg"
+
+	options := "signed-by=" + keyring
+	if repo.Arch != "" {
+		options = "arch=" + repo.Arch + " " + options
+	}
+
+	suite := repo.Suite
+	if suite == "" {
+		suite = "/"
+	}
+	source := strings.TrimSpace(fmt.Sprintf("deb [%s] %s %s %s", options, repo.URL, suite, strings.Join(repo.Components, " ")))
+
+	return []string{
+		"sudo mkdir -p /etc/apt/keyrings",
+		fmt.Sprintf("curl -fsSL %s | sudo gpg --dearmor --yes -o %s", repo.GPGKeyURL, keyring),
+		fmt.Sprintf("echo \"%s\" | sudo tee /etc/apt/sources.list.d/%s.list > /dev/null", source, repo.Name),
+	}
+}
+
+// RemoveRepository deletes an apt source and its keyring
+func (a *APT) RemoveRepository(name string) string {
+	return fmt.Sprintf("sudo rm -f /etc/apt/sources.list.d/%s.list /etc/apt/keyrings/%s.gpg", name, name)
+}
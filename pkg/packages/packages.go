@@ -0,0 +1,66 @@
+// Package packages builds package management commands for the supported distributions
+package packages
+
+import "fmt"
+
+// Manager defines the package management operations used by the installer.
+// Implementations only build shell commands; running them is up to the caller.
+type Manager interface {
+	// Name returns the package manager binary name
+	Name() string
+
+	// Update refreshes the package metadata
+	Update() string
+
+	// Install installs the latest available version of the packages
+	Install(names ...string) string
+
+	// InstallPinned installs the packages at the given upstream version
+	InstallPinned(version string, names ...string) string
+
+	// Hold prevents the packages from being upgraded
+	Hold(names ...string) string
+
+	// Unhold allows previously held packages to be upgraded again
+	Unhold(names ...string) string
+
+	// Remove uninstalls the packages
+	Remove(names ...string) string
+
+	// AddRepository configures a package repository and imports its signing key
+	AddRepository(repo Repository) []string
+
+	// RemoveRepository deletes a repository added with AddRepository
+	RemoveRepository(name string) string
+}
+
+// Repository describes a package repository
+type Repository struct {
+	// Name is used for the repository file, alias and keyring
+	Name string
+	// URL is the repository base URL
+	URL string
+	// GPGKeyURL is the location of the repository signing key
+	GPGKeyURL string
+	// Suite and Components are only used by apt; an empty suite means a flat repository
+	Suite      string
+	Components []string
+	// Arch restricts apt repositories to one architecture
+	Arch string
+}
+
+// ForName returns the manager for the given package manager binary name
+func ForName(name string) (Manager, error) {
+	switch name {
+	case "apt":
+		return NewAPT(), nil
+	case "yum":
+		return NewYum(), nil
+	case "dnf":
+		return NewDNF(), nil
+	case "zypper":
+		return NewZypper(), nil
+	default:
+		return nil, fmt.Errorf("unsupported package manager '%s'", name)
+	}
+}
@@ -0,0 +1,123 @@
+package packages
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestManagerCommands(t *testing.T) {
+	kubernetes := Repository{
+		Name:      "kubernetes",
+		URL:       "https://pkgs.k8s.io/core:/stable:/v1.33/rpm/",
+		GPGKeyURL: "https://pkgs.k8s.io/core:/stable:/v1.33/rpm/repodata/repomd.xml.key",
+	}
+	kubernetesDeb := Repository{
+		Name:      "kubernetes",
+		URL:       "https://pkgs.k8s.io/core:/stable:/v1.33/deb/",
+		GPGKeyURL: "https://pkgs.k8s.io/core:/stable:/v1.33/deb/Release.key",
+	}
+	docker := Repository{
+		Name:       "docker",
+		URL:        "https://download.docker.com/linux/ubuntu",
+		GPGKeyURL:  "https://download.docker.com/linux/ubuntu/gpg",
+		Suite:      "noble",
+		Components: []string{"stable"},
+		Arch:       "amd64",
+	}
+
+	tests := []struct {
+		name    string
+		manager Manager
+		repo    Repository
+		install string
+		pinned  string
+		hold    string
+		addRepo []string
+	}{
+		{
+			name:    "apt flat repository",
+			manager: NewAPT(),
+			repo:    kubernetesDeb,
+			install: "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y kubelet kubeadm",
+			// The Debian revision of pkgs.k8s.io packages is matched with a wildcard
+			pinned: "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y --allow-change-held-packages 'kubelet=1.33.4-*' 'kubeadm=1.33.4-*'",
+			hold:   "sudo apt-mark hold kubelet kubeadm",
+			addRepo: []string{
+				"sudo mkdir -p /etc/apt/keyrings",
+				"curl -fsSL https://pkgs.k8s.io/core:/stable:/v1.33/deb/Release.key | sudo gpg --dearmor --yes -o /etc/apt/keyrings/kubernetes.gpg",
+				`echo "deb [signed-by=/etc/apt/keyrings/kubernetes.gpg] https://pkgs.k8s.io/core:/stable:/v1.33/deb/ /" | sudo tee /etc/apt/sources.list.d/kubernetes.list > /dev/null`,
+			},
+		},
+		{
+			name:    "apt suite repository",
+			manager: NewAPT(),
+			repo:    docker,
+			install: "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y kubelet kubeadm",
+			pinned:  "sudo DEBIAN_FRONTEND=noninteractive apt-get install -y --allow-change-held-packages 'kubelet=1.33.4-*' 'kubeadm=1.33.4-*'",
+			hold:    "sudo apt-mark hold kubelet kubeadm",
+			addRepo: []string{
+				"sudo mkdir -p /etc/apt/keyrings",
+				"curl -fsSL https://download.docker.com/linux/ubuntu/gpg | sudo gpg --dearmor --yes -o /etc/apt/keyrings/docker.gpg",
+				`echo "deb [arch=amd64 signed-by=/etc/apt/keyrings/docker.gpg] https://download.docker.com/linux/ubuntu noble stable" | sudo tee /etc/apt/sources.list.d/docker.list > /dev/null`,
+			},
+		},
+		{
+			name:    "yum",
+			manager: NewYum(),
+			repo:    kubernetes,
+			install: "sudo yum install -y kubelet kubeadm",
+			pinned:  "sudo yum install -y --disableexcludes=all kubelet-1.33.4 kubeadm-1.33.4",
+			hold:    "sudo yum install -y yum-plugin-versionlock && sudo yum versionlock add kubelet kubeadm",
+			addRepo: []string{
+				"sudo rpm --import https://pkgs.k8s.io/core:/stable:/v1.33/rpm/repodata/repomd.xml.key",
+				"cat <<'EOF' | sudo tee /etc/yum.repos.d/kubernetes.repo\n[kubernetes]\nname=kubernetes\n" +
+					"baseurl=https://pkgs.k8s.io/core:/stable:/v1.33/rpm/\nenabled=1\ngpgcheck=1\n" +
+					"gpgkey=https://pkgs.k8s.io/core:/stable:/v1.33/rpm/repodata/repomd.xml.key\nEOF",
+			},
+		},
+		{
+			name:    "dnf",
+			manager: NewDNF(),
+			repo:    kubernetes,
+			install: "sudo dnf install -y kubelet kubeadm",
+			pinned:  "sudo dnf install -y --disableexcludes=all kubelet-1.33.4 kubeadm-1.33.4",
+			hold:    "sudo dnf install -y 'dnf-command(versionlock)' && sudo dnf versionlock add kubelet kubeadm",
+			addRepo: []string{
+				"sudo rpm --import https://pkgs.k8s.io/core:/stable:/v1.33/rpm/repodata/repomd.xml.key",
+				"cat <<'EOF' | sudo tee /etc/yum.repos.d/kubernetes.repo\n[kubernetes]\nname=kubernetes\n" +
+					"baseurl=https://pkgs.k8s.io/core:/stable:/v1.33/rpm/\nenabled=1\ngpgcheck=1\n" +
+					"gpgkey=https://pkgs.k8s.io/core:/stable:/v1.33/rpm/repodata/repomd.xml.key\nEOF",
+			},
+		},
+		{
+			name:    "zypper",
+			manager: NewZypper(),
+			repo:    kubernetes,
+			install: "sudo zypper --non-interactive install kubelet kubeadm",
+			pinned:  "sudo zypper --non-interactive install --oldpackage 'kubelet=1.33.4' 'kubeadm=1.33.4'",
+			hold:    "sudo zypper --non-interactive addlock kubelet kubeadm",
+			addRepo: []string{
+				"sudo rpm --import https://pkgs.k8s.io/core:/stable:/v1.33/rpm/repodata/repomd.xml.key",
+				"sudo zypper --non-interactive removerepo kubernetes || true",
+				"sudo zypper --non-interactive addrepo --refresh --gpgcheck 'https://pkgs.k8s.io/core:/stable:/v1.33/rpm/' kubernetes",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.manager.Install("kubelet", "kubeadm"); got != tt.install {
+				t.Errorf("Install() = %q, want %q", got, tt.install)
+			}
+			if got := tt.manager.InstallPinned("1.33.4", "kubelet", "kubeadm"); got != tt.pinned {
+				t.Errorf("InstallPinned() = %q, want %q", got, tt.pinned)
+			}
+			if got := tt.manager.Hold("kubelet", "kubeadm"); got != tt.hold {
+				t.Errorf("Hold() = %q, want %q", got, tt.hold)
+			}
+			if got := tt.manager.AddRepository(tt.repo); !reflect.DeepEqual(got, tt.addRepo) {
+				t.Errorf("AddRepository() = %q, want %q", got, tt.addRepo)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package packages
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Yum implements the Manager interface for RHEL-based distributions. The dnf
+// manager shares the same repository layout and differs only in its binary and
+// versionlock plugin.
+type Yum struct {
+	binary          string
+	versionlockPkg  string
+	metadataRefresh string
+}
+
+// NewYum creates a new yum package manager
+func NewYum() *Yum {
+	return &Yum{
+		binary:          "yum",
+		versionlockPkg:  "yum-plugin-versionlock",
+		metadataRefresh: "sudo yum makecache -y",
+	}
+}
+
+// NewDNF creates a new dnf package manager
+func NewDNF() *Yum {
+	return &Yum{
+		binary:          "dnf",
+		versionlockPkg:  "'dnf-command(versionlock)'",
+		metadataRefresh: "sudo dnf makecache -y",
+	}
+}
+
+// Name returns the package manager binary name
+func (y *Yum) Name() string {
+	return y.binary
+}
+
+// Update refreshes the package metadata
+func (y *Yum) Update() string {
+	return y.metadataRefresh
+}
+
+// Install installs the latest available version of the packages
+func (y *Yum) Install(names ...string) string {
+	return fmt.Sprintf("sudo %s install -y %s", y.binary, strings.Join(names, " "))
+}
+
+// InstallPinned installs the packages at the given upstream version
+func (y *Yum) InstallPinned(version string, names ...string) string {
+	pinned := make([]string, len(names))
+	for i, name := range names {
+		pinned[i] = name + "-" + version
+	}
+	return fmt.Sprintf("sudo %s install -y --disableexcludes=all %s", y.binary, strings.Join(pinned, " "))
+}
+
+// Hold prevents the packages from being upgraded using the versionlock plugin
+func (y *Yum) Hold(names ...string) string {
+	return fmt.Sprintf("sudo %s install -y %s && sudo %s versionlock add %s",
+		y.binary, y.versionlockPkg, y.binary, strings.Join(names, " "))
+}
+
+// Unhold allows previously held packages to be upgraded again
+func (y *Yum) Unhold(names ...string) string {
+	return fmt.Sprintf("sudo %s versionlock delete %s || true", y.binary, strings.Join(names, " "))
+}
+
+// Remove uninstalls the packages
+func (y *Yum) Remove(names ...string) string {
+	return fmt.Sprintf("sudo %s remove -y %s", y.binary, strings.Join(names, " "))
+}
+
+// AddRepository writes a .repo file and imports its signing key
+func (y *Yum) AddRepository(repo Repository) []string {
+	return []string{
+		fmt.Sprintf("sudo rpm --import %s", repo.GPGKeyURL),
+		// The quoted heredoc keeps $releasever and $basearch for yum to expand
+		fmt.Sprintf("cat <<'EOF' | sudo tee /etc/yum.repos.d/%s.repo\n[%s]\nname=%s\nbaseurl=%s\nenabled=1\ngpgcheck=1\ngpgkey=%s\nEOF",
+			repo.Name, repo.Name, repo.Name, repo.URL, repo.GPGKeyURL),
+	}
+}
+
+// RemoveRepository deletes a .repo file
+func (y *Yum) RemoveRepository(name string) string {
+	return fmt.Sprintf("sudo rm -f /etc/yum.repos.d/%s.repo", name)
+}
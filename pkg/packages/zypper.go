@@ -0,0 +1,67 @@
+package packages
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Zypper implements the Manager interface for SUSE-based distributions
+type Zypper struct{}
+
+// NewZypper creates a new zypper package manager
+func NewZypper() *Zypper {
+	return &Zypper{}
+}
+
+// Name returns the package manager binary name
+func (z *Zypper) Name() string {
+	return "zypper"
+}
+
+// Update refreshes the package metadata
+func (z *Zypper) Update() string {
+	return "sudo zypper --non-interactive --gpg-auto-import-keys refresh"
+}
+
+// Install installs the latest available version of the packages
+func (z *Zypper) Install(names ...string) string {
+	return "sudo zypper --non-interactive install " + strings.Join(names, " ")
+}
+
+// InstallPinned installs the packages at the given upstream version
+func (z *Zypper) InstallPinned(version string, names ...string) string {
+	pinned := make([]string, len(names))
+	for i, name := range names {
+		pinned[i] = fmt.Sprintf("'%s=%s'", name, version)
+	}
+	return "sudo zypper --non-interactive install --oldpackage " + strings.Join(pinned, " ")
+}
+
+// Hold prevents the packages from being upgraded
+func (z *Zypper) Hold(names ...string) string {
+	return "sudo zypper --non-interactive addlock " + strings.Join(names, " ")
+}
+
+// Unhold allows previously held packages to be upgraded again
+func (z *Zypper) Unhold(names ...string) string {
+	return "sudo zypper --non-interactive removelock " + strings.Join(names, " ")
+}
+
+// Remove uninstalls the packages
+func (z *Zypper) Remove(names ...string) string {
+	return "sudo zypper --non-interactive remove " + strings.Join(names, " ")
+}
+
+// AddRepository imports the signing key and adds the repository with auto-refresh
+func (z *Zypper) AddRepository(repo Repository) []string {
+	return []string{
+		fmt.Sprintf("sudo rpm --import %s", repo.GPGKeyURL),
+		fmt.Sprintf("sudo zypper --non-interactive removerepo %s || true", repo.Name),
+		fmt.Sprintf("sudo zypper --non-interactive addrepo --refresh --gpgcheck '%s' %s", repo.URL, repo.Name),
+	}
+}
+
+// RemoveRepository deletes a repository by alias
+func (z *Zypper) RemoveRepository(name string) string {
+	return fmt.Sprintf("sudo zypper --non-interactive removerepo %s || true", name)
+}
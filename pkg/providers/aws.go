@@ -2,105 +2,167 @@ package providers
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
 	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
 )
 
+// awsCloudConfigPath is the AWS cloud controller manager configuration
+const awsCloudConfigPath = "/etc/kubernetes/cloud.conf"
+
 // AWSProvider implements the Provider interface for AWS
 type AWSProvider struct {
 	BaseProvider
+	metadata *awsMetadataClient
 }
 
 // NewAWSProvider creates a new AWS provider
-func NewAWSProvider(client *ssh.Client, cfg *config.Config) *AWSProvider {
+func NewAWSProvider(client ssh.Executor, cfg *config.Config) *AWSProvider {
 	return &AWSProvider{
 		BaseProvider: BaseProvider{
 			Client: client,
 			Config: cfg,
 		},
+		metadata: newAWSMetadataClient(client),
 	}
 }
 
-// GetMetadata retrieves AWS-specific metadata
-func (p *AWSProvider) GetMetadata() (map[string]string, error) {
-	metadata := make(map[string]string)
+// GetMetadata reads the instance metadata through the IMDSv2 client. The instance is
+// named after its Name tag when the instance exposes its tags.
+func (p *AWSProvider) GetMetadata() (*InstanceMetadata, error) {
+	if p.planning() {
+		return plannedMetadata(), nil
+	}
 
-	// AWS metadata commands
-	commands := []struct {
-		key string
-		cmd string
-	}{
-		{"instance-id", "curl -s http://169.254.169.254/latest/meta-data/instance-id"},
-		{"instance-type", "curl -s http://169.254.169.254/latest/meta-data/instance-type"},
-		{"availability-zone", "curl -s http://169.254.169.254/latest/meta-data/placement/availability-zone"},
-		{"region", "curl -s http://169.254.169.254/latest/meta-data/placement/availability-zone | sed 's/[a-z]$//'"},
-		{"local-hostname", "curl -s http://169.254.169.254/latest/meta-data/local-hostname"},
-		{"public-ipv4", "curl -s http://169.254.169.254/latest/meta-data/public-ipv4"},
+	id, err := p.metadata.Get("instance-id")
+	if err != nil {
+		return nil, err
 	}
+	metadata := &InstanceMetadata{ID: id, Name: id}
 
-	// Execute each command and store the result
-	for _, cmd := range commands {
-		output, err := p.Client.RunCommand(cmd.cmd)
-		if err == nil {
-			metadata[cmd.key] = output
-		} else {
-			// Don't fail if one metadata command fails, just log it
-			fmt.Printf("Warning: Failed to get AWS metadata '%s': %v\n", cmd.key, err)
+	fields := []struct {
+		path  string
+		value *string
+	}{
+		{"instance-type", &metadata.Type},
+		{"placement/region", &metadata.Region},
+		{"placement/availability-zone", &metadata.Zone},
+		{"local-hostname", &metadata.Hostname},
+	}
+	for _, field := range fields {
+		value, err := p.metadata.Get(field.path)
+		if err != nil {
+			// Don't fail if one metadata read fails, just log it
+			p.Log.Warnf("Failed to get AWS metadata '%s': %v", field.path, err)
+			continue
 		}
+		*field.value = value
 	}
 
-	// Add common metadata
-	commonCommands := baseMetadataCommands()
-	for _, cmd := range commonCommands {
-		output, err := p.Client.RunCommand(cmd)
-		if err == nil {
-			metadata[cmd] = output
+	if ip, err := p.metadata.Get("local-ipv4"); err == nil {
+		metadata.PrivateIPs = []string{ip}
+	}
+	// Instances without a public address have no public-ipv4
+	if ip, err := p.metadata.Get("public-ipv4"); err == nil {
+		metadata.PublicIPs = []string{ip}
+	}
+
+	// Tags are only readable when the instance exposes them
+	if keys, err := p.metadata.Get("tags/instance/"); err == nil {
+		metadata.Tags = map[string]string{}
+		for _, key := range strings.Fields(keys) {
+			if value, err := p.metadata.Get("tags/instance/" + key); err == nil {
+				metadata.Tags[key] = value
+			}
+		}
+		if name := metadata.Tags["Name"]; name != "" {
+			metadata.Name = name
 		}
 	}
 
+	p.metadataDiscovered(metadata)
 	return metadata, nil
 }
 
-// SetupCloudProvider configures the AWS cloud provider integration
+// SetupCloudProvider deploys the AWS cloud controller manager. It discovers the
+// cluster's instances, subnets and security groups by their
+// "kubernetes.io/cluster/<name>" tags.
 func (p *AWSProvider) SetupCloudProvider() error {
 	// Check if instance has IAM role with EC2 permissions
-	checkIamCmd := "curl -s http://169.254.169.254/latest/meta-data/iam/security-credentials/"
-	iamRole, err := p.Client.RunCommand(checkIamCmd)
+	iamRole, err := p.metadata.Get("iam/security-credentials/")
 	if err != nil || iamRole == "" {
-		fmt.Println("Warning: No IAM role found for this instance. Cloud provider integration may not work correctly.")
-		fmt.Println("         Please attach an IAM role with EC2 permissions to this instance.")
+		p.Log.Warnf("No IAM role found for this instance. Cloud provider integration may not work correctly.\n         Please attach an IAM role with EC2 permissions to this instance.")
 	}
+	p.checkClusterTag()
 
-	// AWS cloud provider commands
-	commands := []string{
-		// Create a minimal AWS cloud provider config
-		"cat <<EOF | sudo tee /etc/kubernetes/cloud.conf\n[global]\nKubernetesClusterID=kubernetes\nEOF",
-		"sudo chmod 600 /etc/kubernetes/cloud.conf",
-		// Create secret from the cloud.conf file
-		"kubectl -n kube-system create secret generic aws-cloud-provider --from-file=/etc/kubernetes/cloud.conf || true",
+	cloudConf := fmt.Sprintf("[Global]\nKubernetesClusterTag=%s\nKubernetesClusterID=%s\n", p.clusterName(), p.clusterName())
+	if err := p.Client.WriteFile(awsCloudConfigPath, []byte(cloudConf), 0600); err != nil {
+		return err
 	}
 
-	return p.Client.RunCommands(commands)
+	return p.deployCloudControllerManager(cloudControllerManager{
+		Name:  "aws-cloud-controller-manager",
+		Image: "registry.k8s.io/provider-aws/cloud-controller-manager:" + p.ccmVersion(),
+		Args: []string{
+			"--v=2",
+			"--cloud-provider=aws",
+			"--cloud-config=/etc/kubernetes/cloud/cloud.conf",
+			"--cluster-name=" + p.clusterName(),
+			"--configure-cloud-routes=false",
+			"--use-service-account-credentials=false",
+		},
+		ConfigSecret: "aws-cloud-provider",
+		ConfigDir:    "/etc/kubernetes/cloud",
+	}, awsCloudConfigPath)
+}
+
+// checkClusterTag warns when the instance lacks the tag the cloud controller manager
+// finds the cluster's instances by. Tags can only be checked when the instance
+// exposes them through the metadata service.
+func (p *AWSProvider) checkClusterTag() {
+	tags, err := p.metadata.Get("tags/instance/")
+	if err != nil {
+		p.Log.Infof("Instance tags are not available from the metadata service; make sure every instance is tagged %s", p.clusterTag())
+		return
+	}
+	for _, tag := range strings.Fields(tags) {
+		if tag == p.clusterTag() {
+			return
+		}
+	}
+	p.Log.Warnf("Instance is not tagged %s=owned or shared. The cloud controller manager will not find it.", p.clusterTag())
+}
+
+// clusterTag returns the tag key of the cluster's AWS resources
+func (p *AWSProvider) clusterTag() string {
+	return "kubernetes.io/cluster/" + p.clusterName()
+}
+
+// CloudProviderInstalled returns true once the cloud controller manager is deployed
+func (p *AWSProvider) CloudProviderInstalled() (bool, error) {
+	return p.installed("kube-system", "daemonset/aws-cloud-controller-manager")
 }
 
-// GetCloudProviderOptions returns AWS cloud provider-specific options for kubeadm
+// GetCloudProviderOptions returns AWS cloud provider-specific options for kubeadm.
+// The in-tree AWS provider is gone, so the kubelets defer to the cloud controller manager.
 func (p *AWSProvider) GetCloudProviderOptions() string {
-	return "--cloud-provider=aws --cloud-config=/etc/kubernetes/cloud.conf"
+	return externalCloudProvider
 }
 
 // DisplayInfo shows AWS-specific information
 func (p *AWSProvider) DisplayInfo() {
-	fmt.Println("\n====== AWS Cloud Provider Information ======")
-	fmt.Println("For AWS cloud provider integration:")
-	fmt.Println("1. Ensure your EC2 instance has an IAM role with the following permissions:")
-	fmt.Println("   - AmazonEC2FullAccess")
-	fmt.Println("   - AmazonRoute53FullAccess (if using Route53 for DNS)")
-	fmt.Println("2. Tag your AWS resources with the following tags:")
-	fmt.Println("   - KubernetesCluster=<your-cluster-name>")
-	fmt.Println("3. For load balancers, add the following tags to your subnets:")
-	fmt.Println("   - kubernetes.io/cluster/<your-cluster-name>=shared")
-	fmt.Println("4. For more information, visit:")
-	fmt.Println("   https://kubernetes.io/docs/concepts/cluster-administration/cloud-providers/#aws")
-	fmt.Println("================================================")
+	p.info(
+		"\n====== AWS Cloud Provider Information ======",
+		"The AWS cloud controller manager runs on the control plane nodes.",
+		"1. Ensure your EC2 instances have an IAM role with the permissions listed at:",
+		"   https://cloud-provider-aws.sigs.k8s.io/prerequisites/",
+		"2. Tag the instances, subnets and security groups of the cluster with:",
+		"   - "+p.clusterTag()+"=owned (or shared)",
+		"3. For load balancers, also tag your subnets with:",
+		"   - kubernetes.io/role/elb=1 (public) or kubernetes.io/role/internal-elb=1 (private)",
+		"4. For more information, visit:",
+		"   https://github.com/kubernetes/cloud-provider-aws",
+		"================================================",
+	)
 }
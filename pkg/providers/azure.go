@@ -1,6 +1,8 @@
 package providers
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -8,13 +10,24 @@ import (
 	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
 )
 
+const (
+	// azureConfigPath is the Azure cloud controller manager configuration
+	azureConfigPath = "/etc/kubernetes/azure.json"
+
+	// azureMetadataURL is the instance metadata service
+	azureMetadataURL = "http://169.254.169.254/metadata/"
+)
+
+// azureMetadataHeaders are required by the instance metadata service on every request
+var azureMetadataHeaders = map[string]string{"Metadata": "true"}
+
 // AzureProvider implements the Provider interface for Azure
 type AzureProvider struct {
 	BaseProvider
 }
 
 // NewAzureProvider creates a new Azure provider
-func NewAzureProvider(client *ssh.Client, cfg *config.Config) *AzureProvider {
+func NewAzureProvider(client ssh.Executor, cfg *config.Config) *AzureProvider {
 	return &AzureProvider{
 		BaseProvider: BaseProvider{
 			Client: client,
@@ -23,101 +36,398 @@ func NewAzureProvider(client *ssh.Client, cfg *config.Config) *AzureProvider {
 	}
 }
 
-// GetMetadata retrieves Azure-specific metadata
-func (p *AzureProvider) GetMetadata() (map[string]string, error) {
-	metadata := make(map[string]string)
+// azureInstance is the part of the instance metadata document GetMetadata reads
+type azureInstance struct {
+	Compute struct {
+		VMID     string `json:"vmId"`
+		Name     string `json:"name"`
+		VMSize   string `json:"vmSize"`
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		TagsList []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"tagsList"`
+	} `json:"compute"`
+	Network struct {
+		Interface []struct {
+			IPv4 struct {
+				IPAddress []struct {
+					PrivateIPAddress string `json:"privateIpAddress"`
+					PublicIPAddress  string `json:"publicIpAddress"`
+				} `json:"ipAddress"`
+			} `json:"ipv4"`
+		} `json:"interface"`
+	} `json:"network"`
+}
+
+// GetMetadata reads the instance document from the instance metadata service. The
+// zone is empty for VMs outside availability zones.
+func (p *AzureProvider) GetMetadata() (*InstanceMetadata, error) {
+	if p.planning() {
+		return plannedMetadata(), nil
+	}
 
-	// Azure metadata commands
-	commands := []struct {
-		key string
-		cmd string
-	}{
-		{"vm-name", "curl -s -H Metadata:true 'http://169.254.169.254/metadata/instance/compute/name?api-version=2019-06-01&format=text'"},
-		{"resource-group", "curl -s -H Metadata:true 'http://169.254.169.254/metadata/instance/compute/resourceGroupName?api-version=2019-06-01&format=text'"},
-		{"subscription-id", "curl -s -H Metadata:true 'http://169.254.169.254/metadata/instance/compute/subscriptionId?api-version=2019-06-01&format=text'"},
-		{"location", "curl -s -H Metadata:true 'http://169.254.169.254/metadata/instance/compute/location?api-version=2019-06-01&format=text'"},
-		{"vm-size", "curl -s -H Metadata:true 'http://169.254.169.254/metadata/instance/compute/vmSize?api-version=2019-06-01&format=text'"},
-		{"public-ipv4", "curl -s -H Metadata:true 'http://169.254.169.254/metadata/instance/network/interface/0/ipv4/ipAddress/0/publicIpAddress?api-version=2019-06-01&format=text'"},
-	}
-
-	// Execute each command and store the result
-	for _, cmd := range commands {
-		output, err := p.Client.RunCommand(cmd.cmd)
-		if err == nil {
-			metadata[cmd.key] = output
-		} else {
-			// Don't fail if one metadata command fails, just log it
-			fmt.Printf("Warning: Failed to get Azure metadata '%s': %v\n", cmd.key, err)
-		}
+	var instance azureInstance
+	if err := p.fetchJSON(azureMetadataURL+"instance?api-version=2021-02-01", azureMetadataHeaders, &instance); err != nil {
+		return nil, fmt.Errorf("failed to read Azure metadata: %v", err)
 	}
 
-	// Add common metadata
-	commonCommands := baseMetadataCommands()
-	for _, cmd := range commonCommands {
-		output, err := p.Client.RunCommand(cmd)
-		if err == nil {
-			metadata[cmd] = output
+	compute := instance.Compute
+	metadata := &InstanceMetadata{
+		ID:     compute.VMID,
+		Name:   compute.Name,
+		Type:   compute.VMSize,
+		Region: compute.Location,
+		Zone:   compute.Zone,
+		// The cloud node manager finds VMs by their name
+		Hostname: compute.Name,
+		Tags:     map[string]string{},
+	}
+	for _, nic := range instance.Network.Interface {
+		for _, address := range nic.IPv4.IPAddress {
+			if address.PrivateIPAddress != "" {
+				metadata.PrivateIPs = append(metadata.PrivateIPs, address.PrivateIPAddress)
+			}
+			if address.PublicIPAddress != "" {
+				metadata.PublicIPs = append(metadata.PublicIPs, address.PublicIPAddress)
+			}
 		}
 	}
+	for _, tag := range compute.TagsList {
+		metadata.Tags[tag.Name] = tag.Value
+	}
 
+	p.metadataDiscovered(metadata)
 	return metadata, nil
 }
 
-// SetupCloudProvider configures the Azure cloud provider integration
+// SetupCloudProvider writes azure.json from the instance metadata and the VM's network
+// interface, applies the overrides from the configuration, and deploys the Azure
+// cloud controller manager and cloud node manager
 func (p *AzureProvider) SetupCloudProvider() error {
-	// Get the required metadata for cloud.conf
-	metadata, err := p.GetMetadata()
+	conf := p.discoverConfig()
+	if len(p.Config.Azure) > 0 {
+		// Unknown fields are rejected so that a misspelled override is not silently ignored
+		decoder := json.NewDecoder(bytes.NewReader(p.Config.Azure))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(conf); err != nil {
+			return fmt.Errorf("invalid azure configuration: %v", err)
+		}
+	}
+	if err := conf.validate(); err != nil {
+		return err
+	}
+
+	var azureJSON bytes.Buffer
+	encoder := json.NewEncoder(&azureJSON)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(conf); err != nil {
+		return fmt.Errorf("failed to render azure.json: %v", err)
+	}
+	if err := p.Client.WriteFile(azureConfigPath, azureJSON.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	err := p.deployCloudControllerManager(cloudControllerManager{
+		Name:    "azure-cloud-controller-manager",
+		Image:   "mcr.microsoft.com/oss/kubernetes/azure-cloud-controller-manager:" + p.ccmVersion(),
+		Command: []string{"cloud-controller-manager"},
+		Args: []string{
+			"--v=2",
+			"--cloud-provider=azure",
+			"--cloud-config=/etc/kubernetes/cloud/azure.json",
+			"--cluster-name=" + p.clusterName(),
+			// The cloud node manager initializes the nodes
+			"--controllers=*,-cloud-node",
+			// Flannel assigns pod CIDRs and routes
+			"--allocate-node-cidrs=false",
+			"--configure-cloud-routes=false",
+		},
+		ConfigSecret: "azure-cloud-provider",
+		ConfigDir:    "/etc/kubernetes/cloud",
+	}, azureConfigPath)
 	if err != nil {
-		return fmt.Errorf("failed to get Azure metadata: %v", err)
+		return err
+	}
+
+	manifest, err := renderManifest("azure-cloud-node-manager", map[string]string{
+		"Image": "mcr.microsoft.com/oss/kubernetes/azure-cloud-node-manager:" + p.ccmVersion(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.applyManifest("azure-cloud-node-manager", manifest); err != nil {
+		return fmt.Errorf("failed to deploy azure-cloud-node-manager: %v", err)
+	}
+	return nil
+}
+
+// azureConfig is the azure.json read by the Azure cloud controller manager
+type azureConfig struct {
+	Cloud          string `json:"cloud"`
+	TenantID       string `json:"tenantId"`
+	SubscriptionID string `json:"subscriptionId"`
+	ResourceGroup  string `json:"resourceGroup"`
+	Location       string `json:"location"`
+	// VMType is "standard" for availability sets and single VMs, or "vmss"
+	VMType                     string `json:"vmType"`
+	VnetName                   string `json:"vnetName"`
+	VnetResourceGroup          string `json:"vnetResourceGroup,omitempty"`
+	SubnetName                 string `json:"subnetName"`
+	SecurityGroupName          string `json:"securityGroupName"`
+	SecurityGroupResourceGroup string `json:"securityGroupResourceGroup,omitempty"`
+	RouteTableName             string `json:"routeTableName,omitempty"`
+	RouteTableResourceGroup    string `json:"routeTableResourceGroup,omitempty"`
+	LoadBalancerSku            string `json:"loadBalancerSku"`
+	// Either a managed identity or a service principal authenticates the managers
+	UseManagedIdentityExtension bool   `json:"useManagedIdentityExtension"`
+	UserAssignedIdentityID      string `json:"userAssignedIdentityID,omitempty"`
+	AADClientID                 string `json:"aadClientId,omitempty"`
+	AADClientSecret             string `json:"aadClientSecret,omitempty"`
+	UseInstanceMetadata         bool   `json:"useInstanceMetadata"`
+}
+
+// validate checks that azure.json has everything load balancers need
+func (c *azureConfig) validate() error {
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"tenantId", c.TenantID},
+		{"subscriptionId", c.SubscriptionID},
+		{"resourceGroup", c.ResourceGroup},
+		{"location", c.Location},
+		{"vnetName", c.VnetName},
+		{"subnetName", c.SubnetName},
+		{"securityGroupName", c.SecurityGroupName},
+	}
+	var missing []string
+	for _, field := range required {
+		if field.value == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("azure.json is missing %s: set them in the \"azure\" section of the config file", strings.Join(missing, ", "))
+	}
+
+	switch {
+	case c.VMType != "standard" && c.VMType != "vmss":
+		return fmt.Errorf("invalid azure vmType '%s': use standard or vmss", c.VMType)
+	case c.LoadBalancerSku != "basic" && c.LoadBalancerSku != "standard":
+		return fmt.Errorf("invalid azure loadBalancerSku '%s': use basic or standard", c.LoadBalancerSku)
+	case !c.UseManagedIdentityExtension && (c.AADClientID == "" || c.AADClientSecret == ""):
+		return fmt.Errorf("azure.json needs useManagedIdentityExtension or aadClientId and aadClientSecret")
+	}
+	return nil
+}
+
+// discoverConfig fills in azure.json from the instance metadata service and the
+// Azure Resource Manager API. Values that cannot be read are left empty and
+// reported, so that overrides can supply them.
+func (p *AzureProvider) discoverConfig() *azureConfig {
+	conf := &azureConfig{
+		Cloud:                       "AzurePublicCloud",
+		VMType:                      "standard",
+		LoadBalancerSku:             "standard",
+		UseManagedIdentityExtension: true,
+		UseInstanceMetadata:         true,
+	}
+	if p.planning() {
+		// Nothing can be discovered without a host
+		for _, field := range []*string{&conf.TenantID, &conf.SubscriptionID, &conf.ResourceGroup, &conf.Location, &conf.VnetName, &conf.SubnetName, &conf.SecurityGroupName} {
+			*field = "<discovered>"
+		}
+		return conf
+	}
+
+	var compute struct {
+		AzEnvironment     string `json:"azEnvironment"`
+		Location          string `json:"location"`
+		ResourceGroupName string `json:"resourceGroupName"`
+		ResourceID        string `json:"resourceId"`
+		SubscriptionID    string `json:"subscriptionId"`
+		VMScaleSetName    string `json:"vmScaleSetName"`
+	}
+	if err := p.fetchJSON(azureMetadataURL+"instance/compute?api-version=2021-02-01", azureMetadataHeaders, &compute); err != nil {
+		p.Log.Warnf("Failed to read Azure instance metadata: %v", err)
+		return conf
+	}
+	conf.SubscriptionID = compute.SubscriptionID
+	conf.ResourceGroup = compute.ResourceGroupName
+	conf.Location = compute.Location
+	if compute.AzEnvironment != "" {
+		conf.Cloud = compute.AzEnvironment
+	}
+	if compute.VMScaleSetName != "" {
+		conf.VMType = "vmss"
+	}
+
+	var identity struct {
+		TenantID string `json:"tenantId"`
+	}
+	if err := p.fetchJSON(azureMetadataURL+"identity/info?api-version=2018-02-01", azureMetadataHeaders, &identity); err != nil {
+		p.Log.Warnf("Failed to read the Azure tenant from the identity endpoint: %v", err)
+	}
+	conf.TenantID = identity.TenantID
+
+	if err := p.discoverNetwork(conf, compute.ResourceID); err != nil {
+		p.Log.Warnf("Failed to read the VM's network from Azure Resource Manager: %v\n         Ensure the VM's managed identity can read its network interface, or set the network in the config file.", err)
+	}
+	return conf
+}
+
+// discoverNetwork reads the virtual network, subnet, network security group and route
+// table of the VM's primary network interface. A security group on the interface
+// takes precedence over the subnet's.
+func (p *AzureProvider) discoverNetwork(conf *azureConfig, vmID string) error {
+	var vm struct {
+		Properties struct {
+			NetworkProfile struct {
+				NetworkInterfaces []struct {
+					ID         string `json:"id"`
+					Properties struct {
+						Primary bool `json:"primary"`
+					} `json:"properties"`
+				} `json:"networkInterfaces"`
+			} `json:"networkProfile"`
+		} `json:"properties"`
+	}
+	if err := p.resourceManager(vmID, "2023-03-01", &vm); err != nil {
+		return err
+	}
+	nics := vm.Properties.NetworkProfile.NetworkInterfaces
+	if len(nics) == 0 {
+		return fmt.Errorf("VM has no network interfaces")
+	}
+	nicID := nics[0].ID
+	for _, nic := range nics {
+		if nic.Properties.Primary {
+			nicID = nic.ID
+		}
+	}
+
+	type reference struct {
+		ID string `json:"id"`
+	}
+	var nic struct {
+		Properties struct {
+			NetworkSecurityGroup *reference `json:"networkSecurityGroup"`
+			IPConfigurations     []struct {
+				Properties struct {
+					Subnet reference `json:"subnet"`
+				} `json:"properties"`
+			} `json:"ipConfigurations"`
+		} `json:"properties"`
+	}
+	if err := p.resourceManager(nicID, "2023-05-01", &nic); err != nil {
+		return err
+	}
+	if len(nic.Properties.IPConfigurations) == 0 {
+		return fmt.Errorf("network interface has no IP configurations")
 	}
 
-	subscriptionID := strings.TrimSpace(metadata["subscription-id"])
-	resourceGroup := strings.TrimSpace(metadata["resource-group"])
-	location := strings.TrimSpace(metadata["location"])
+	// ".../virtualNetworks/<vnet>/subnets/<subnet>"
+	subnetID := nic.Properties.IPConfigurations[0].Properties.Subnet.ID
+	conf.VnetName = resourceIDPart(subnetID, "virtualNetworks")
+	conf.SubnetName = resourceIDPart(subnetID, "subnets")
+	conf.VnetResourceGroup = otherResourceGroup(subnetID, conf.ResourceGroup)
 
-	if subscriptionID == "" || resourceGroup == "" || location == "" {
-		fmt.Println("Warning: Azure metadata incomplete. Cloud provider integration may not work correctly.")
+	var subnet struct {
+		Properties struct {
+			NetworkSecurityGroup *reference `json:"networkSecurityGroup"`
+			RouteTable           *reference `json:"routeTable"`
+		} `json:"properties"`
+	}
+	if err := p.resourceManager(subnetID, "2023-05-01", &subnet); err != nil {
+		return err
 	}
 
-	// Azure cloud provider commands
-	commands := []string{
-		// Create the Azure cloud provider config file
-		fmt.Sprintf(`cat <<EOF | sudo tee /etc/kubernetes/azure.json
-{
-  "cloud": "AzurePublicCloud",
-  "tenantId": "",
-  "subscriptionId": "%s",
-  "resourceGroup": "%s",
-  "location": "%s",
-  "useManagedIdentityExtension": true,
-  "useInstanceMetadata": true
+	nsg := nic.Properties.NetworkSecurityGroup
+	if nsg == nil {
+		nsg = subnet.Properties.NetworkSecurityGroup
+	}
+	if nsg != nil {
+		conf.SecurityGroupName = resourceIDPart(nsg.ID, "networkSecurityGroups")
+		conf.SecurityGroupResourceGroup = otherResourceGroup(nsg.ID, conf.ResourceGroup)
+	} else {
+		p.Log.Warnf("Neither the network interface nor the subnet has a network security group; set securityGroupName in the config file")
+	}
+	if table := subnet.Properties.RouteTable; table != nil {
+		conf.RouteTableName = resourceIDPart(table.ID, "routeTables")
+		conf.RouteTableResourceGroup = otherResourceGroup(table.ID, conf.ResourceGroup)
+	}
+	return nil
 }
-EOF`, subscriptionID, resourceGroup, location),
-		"sudo chmod 600 /etc/kubernetes/azure.json",
-		// Create secret from the azure.json file
-		"kubectl -n kube-system create secret generic azure-cloud-provider --from-file=/etc/kubernetes/azure.json || true",
+
+// resourceManager reads an Azure Resource Manager resource with the VM's managed
+// identity and decodes it into v
+func (p *AzureProvider) resourceManager(resourceID, apiVersion string, v interface{}) error {
+	token, err := p.accessToken(azureMetadataURL+"identity/oauth2/token?api-version=2018-02-01&resource=https://management.azure.com/", azureMetadataHeaders)
+	if err != nil {
+		return err
 	}
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	url := "https://management.azure.com" + resourceID + "?api-version=" + apiVersion
+	if err := p.fetchJSON(url, headers, v); err != nil {
+		return fmt.Errorf("%s: %v", resourceID, err)
+	}
+	return nil
+}
+
+// resourceIDPart returns the segment following the key in an Azure resource ID, such
+// as the resource group for "resourceGroups"
+func resourceIDPart(resourceID, key string) string {
+	parts := strings.Split(resourceID, "/")
+	for n := 0; n+1 < len(parts); n++ {
+		if strings.EqualFold(parts[n], key) {
+			return parts[n+1]
+		}
+	}
+	return ""
+}
+
+// otherResourceGroup returns the resource group of the resource if it differs from
+// the cluster's, since azure.json only needs it then
+func otherResourceGroup(resourceID, resourceGroup string) string {
+	group := resourceIDPart(resourceID, "resourceGroups")
+	if strings.EqualFold(group, resourceGroup) {
+		return ""
+	}
+	return group
+}
 
-	return p.Client.RunCommands(commands)
+// CloudProviderInstalled returns true once the cloud controller manager and cloud
+// node manager are deployed
+func (p *AzureProvider) CloudProviderInstalled() (bool, error) {
+	return p.installed("kube-system", "daemonset/azure-cloud-controller-manager", "daemonset/azure-cloud-node-manager")
 }
 
-// GetCloudProviderOptions returns Azure cloud provider-specific options for kubeadm
+// GetCloudProviderOptions returns Azure cloud provider-specific options for kubeadm.
+// The kubelets defer to the cloud controller manager and cloud node manager.
 func (p *AzureProvider) GetCloudProviderOptions() string {
-	return "--cloud-provider=azure --cloud-config=/etc/kubernetes/azure.json"
+	return externalCloudProvider
 }
 
 // DisplayInfo shows Azure-specific information
 func (p *AzureProvider) DisplayInfo() {
-	fmt.Println("\n====== Azure Cloud Provider Information ======")
-	fmt.Println("For Azure cloud provider integration:")
-	fmt.Println("1. Ensure your VM has a Managed Identity with:")
-	fmt.Println("   - Contributor role on the resource group")
-	fmt.Println("   - Network Contributor role (for load balancer configuration)")
-	fmt.Println("2. For load balancers, ensure your network is properly configured with:")
-	fmt.Println("   - Network security group allowing health probe traffic")
-	fmt.Println("   - Firewall rules allowing port 10256 for health checks")
-	fmt.Println("3. For multi-node clusters, all VMs should be in the same resource group")
-	fmt.Println("4. For more information, visit:")
-	fmt.Println("   https://kubernetes.io/docs/concepts/cluster-administration/cloud-providers/#azure")
-	fmt.Println("================================================")
+	p.info(
+		"\n====== Azure Cloud Provider Information ======",
+		"The Azure cloud controller manager runs on the control plane nodes and the",
+		"cloud node manager on every node.",
+		"1. Ensure your VMs have a Managed Identity with:",
+		"   - Contributor role on the resource group",
+		"   - Network Contributor role (for load balancer configuration)",
+		"2. For load balancers, ensure your network is properly configured with:",
+		"   - Network security group allowing health probe traffic",
+		"   - Firewall rules allowing port 10256 for health checks",
+		"3. For multi-node clusters, all VMs should be in the same resource group",
+		"4. Review the generated "+azureConfigPath+" and override settings in the",
+		"   \"azure\" section of the config file if needed",
+		"5. For more information, visit:",
+		"   https://cloud-provider-azure.sigs.k8s.io/",
+		"================================================",
+	)
 }
@@ -0,0 +1,190 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+)
+
+const (
+	// metalLBVersion is the MetalLB release installed unless the config names another
+	metalLBVersion = "v0.14.9"
+
+	// metalLBManifest is the URL of a release's manifest, by version
+	metalLBManifest = "https://raw.githubusercontent.com/metallb/metallb/%s/config/manifests/metallb-native.yaml"
+)
+
+// BareMetalProvider implements the Provider interface for hosts without a cloud, such
+// as bare-metal servers and VMs on on-prem hypervisors. Nothing is read from a
+// metadata service and no cloud controller manager is deployed.
+type BareMetalProvider struct {
+	BaseProvider
+}
+
+// NewBareMetalProvider creates a new provider without cloud integration
+func NewBareMetalProvider(client ssh.Executor, cfg *config.Config) *BareMetalProvider {
+	return &BareMetalProvider{
+		BaseProvider: BaseProvider{
+			Client: client,
+			Config: cfg,
+		},
+	}
+}
+
+// GetMetadata describes the host from its chosen interface. The node is named after
+// the name the interface's address resolves to in DNS or /etc/hosts, and keeps its
+// current hostname when the address has none.
+func (p *BareMetalProvider) GetMetadata() (*InstanceMetadata, error) {
+	if p.planning() {
+		return plannedMetadata(), nil
+	}
+
+	ip, err := p.NodeIP()
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := p.Client.RunCommand("hostname")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hostname: %v", err)
+	}
+	hostname = strings.TrimSpace(hostname)
+	// "getent hosts" prints the address followed by its names
+	if output, err := p.Client.RunCommand("getent hosts " + ip); err == nil {
+		if fields := strings.Fields(output); len(fields) > 1 {
+			hostname, _, _ = strings.Cut(fields[1], ".")
+		}
+	}
+
+	metadata := &InstanceMetadata{
+		ID:         hostname,
+		Name:       hostname,
+		PrivateIPs: []string{ip},
+		Hostname:   hostname,
+	}
+
+	p.metadataDiscovered(metadata)
+	return metadata, nil
+}
+
+// NodeIP returns the IPv4 address of the configured interface, or of the default
+// route's interface, which the kubelet registers the node with
+func (p *BareMetalProvider) NodeIP() (string, error) {
+	if p.planning() {
+		return "<discovered>", nil
+	}
+
+	iface := ""
+	if p.Config.BareMetal != nil {
+		iface = p.Config.BareMetal.Interface
+	}
+	if iface == "" {
+		// "default via 10.0.0.1 dev eth0 proto dhcp metric 100"
+		output, err := p.Client.RunCommand("ip -4 route show default")
+		if err != nil {
+			return "", fmt.Errorf("failed to read the default route: %v", err)
+		}
+		iface = fieldAfter(output, "dev")
+		if iface == "" {
+			return "", fmt.Errorf("the host has no default route: set baremetal.interface in the config file")
+		}
+	}
+
+	// "2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0 ..."
+	output, err := p.Client.RunCommand("ip -4 -o addr show dev " + iface + " scope global")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the address of %s: %v", iface, err)
+	}
+	address, _, _ := strings.Cut(fieldAfter(output, "inet"), "/")
+	if address == "" {
+		return "", fmt.Errorf("interface %s has no IPv4 address", iface)
+	}
+	return address, nil
+}
+
+// fieldAfter returns the word following the keyword in the output, or an empty string
+func fieldAfter(output, keyword string) string {
+	fields := strings.Fields(output)
+	for n := 0; n+1 < len(fields); n++ {
+		if fields[n] == keyword {
+			return fields[n+1]
+		}
+	}
+	return ""
+}
+
+// SetupCloudProvider installs MetalLB with the address pool from the config file, so
+// that Services of type LoadBalancer get addresses. Without a pool there is nothing
+// to set up.
+func (p *BareMetalProvider) SetupCloudProvider() error {
+	if p.Config.BareMetal == nil || p.Config.BareMetal.MetalLB == nil {
+		p.Log.Infof("No load balancer is installed; Services of type LoadBalancer stay pending without one")
+		return nil
+	}
+	metalLB := p.Config.BareMetal.MetalLB
+	if len(metalLB.Addresses) == 0 {
+		return fmt.Errorf("baremetal.metallb.addresses needs at least one address range")
+	}
+	version := metalLB.Version
+	if version == "" {
+		version = metalLBVersion
+	}
+
+	err := p.Client.RunCommands([]string{
+		kubectl + " apply -f " + fmt.Sprintf(metalLBManifest, version),
+		// The controller validates address pools through a webhook, so it must be up first
+		kubectl + " -n metallb-system wait --for=condition=Available deployment/controller --timeout=5m",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deploy MetalLB: %v", err)
+	}
+
+	manifest, err := renderManifest("metallb-pool", metalLB)
+	if err != nil {
+		return err
+	}
+	if err := p.applyManifest("metallb-pool", manifest); err != nil {
+		return fmt.Errorf("failed to configure the MetalLB address pool: %v", err)
+	}
+	return nil
+}
+
+// CloudProviderInstalled returns true once MetalLB and its address pool are deployed,
+// or right away when no MetalLB is configured. A changed pool is applied with
+// -only-step=cloud-provider.
+func (p *BareMetalProvider) CloudProviderInstalled() (bool, error) {
+	if p.Config.BareMetal == nil || p.Config.BareMetal.MetalLB == nil {
+		return true, nil
+	}
+	return p.installed("metallb-system", "deployment/controller", "ipaddresspool/default", "l2advertisement/default")
+}
+
+// GetCloudProviderOptions returns no options; without a cloud provider the kubelets
+// initialize their nodes themselves
+func (p *BareMetalProvider) GetCloudProviderOptions() string {
+	return ""
+}
+
+// DisplayInfo shows information for clusters without a cloud provider
+func (p *BareMetalProvider) DisplayInfo() {
+	loadBalancer := "No load balancer is installed; add \"metallb\" with an address pool to"
+	if p.Config.BareMetal != nil && p.Config.BareMetal.MetalLB != nil {
+		loadBalancer = "MetalLB assigns Services of type LoadBalancer addresses from the pool in"
+	}
+	p.info(
+		"\n====== Bare Metal Information ======",
+		"Nodes register with the address of the interface chosen in the \"baremetal\"",
+		"section of the config file, or of the default route's interface.",
+		"1. "+loadBalancer,
+		"   the \"baremetal\" section of the config file",
+		"2. Persistent volumes need a storage provisioner, such as a CSI driver for",
+		"   your storage system",
+		"3. For networking, ensure your firewalls allow:",
+		"   - Pod-to-Pod communication",
+		"   - NodePort services (30000-32767)",
+		"   - Control plane communication (6443, 10250-10252)",
+		"   - MetalLB memberlist (7946 TCP and UDP) when MetalLB is installed",
+		"====================================",
+	)
+}
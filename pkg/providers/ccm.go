@@ -0,0 +1,117 @@
+package providers
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// manifests are the Kubernetes manifests the providers deploy, as Go templates
+//
+//go:embed manifests/*.yaml
+var manifests embed.FS
+
+const (
+	// kubectl runs kubectl as root with the admin kubeconfig, which can read the
+	// root-owned cloud configuration files
+	kubectl = "sudo kubectl --kubeconfig /etc/kubernetes/admin.conf"
+
+	// addonsDir holds the rendered manifests applied to the cluster
+	addonsDir = "/etc/kubernetes/addons"
+
+	// externalCloudProvider makes the kubelets and the controller manager leave cloud
+	// integration to an out-of-tree cloud controller manager
+	externalCloudProvider = "--cloud-provider=external"
+
+	// defaultClusterName is the cluster ID cloud controller managers assume when none is set
+	defaultClusterName = "kubernetes"
+)
+
+// cloudControllerManager is rendered into the service account, RBAC rules and
+// control-plane DaemonSet of an out-of-tree cloud controller manager
+type cloudControllerManager struct {
+	Name  string
+	Image string
+	// Command overrides the image entrypoint when set
+	Command []string
+	Args    []string
+	// ConfigSecret is mounted at ConfigDir when set
+	ConfigSecret string
+	ConfigDir    string
+}
+
+// renderManifest executes the named manifest template
+func renderManifest(name string, data interface{}) ([]byte, error) {
+	tmpl, err := template.ParseFS(manifests, path.Join("manifests", name+".yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifest %s: %v", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render manifest %s: %v", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// clusterName returns the name the cluster is known by to the cloud provider
+func (p *BaseProvider) clusterName() string {
+	if p.Config.ClusterName != "" {
+		return p.Config.ClusterName
+	}
+	return defaultClusterName
+}
+
+// ccmVersion returns the cloud controller manager release matching the Kubernetes
+// minor version; providers release one per Kubernetes minor
+func (p *BaseProvider) ccmVersion() string {
+	return p.Config.KubernetesMinorVersion() + ".0"
+}
+
+// applyManifest writes the manifest to the addons directory and applies it
+func (p *BaseProvider) applyManifest(name string, manifest []byte) error {
+	manifestPath := path.Join(addonsDir, name+".yaml")
+	if err := p.Client.WriteFile(manifestPath, manifest, 0600); err != nil {
+		return err
+	}
+	return p.Client.RunCommands([]string{kubectl + " apply -f " + manifestPath})
+}
+
+// installed returns true if all the resources, such as "daemonset/name", exist in
+// the namespace
+func (p *BaseProvider) installed(namespace string, resources ...string) (bool, error) {
+	_, _, err := p.Client.RunCommandWithOutput(kubectl + " -n " + namespace + " get " + strings.Join(resources, " "))
+	return err == nil, nil
+}
+
+// createSecret creates or updates a kube-system secret holding the files
+func (p *BaseProvider) createSecret(name string, files ...string) error {
+	command := kubectl + " -n kube-system create secret generic " + name
+	for _, file := range files {
+		command += " --from-file=" + file
+	}
+	// Rendering and applying the secret updates it when it already exists
+	command += " --dry-run=client -o yaml | " + kubectl + " apply -f -"
+	return p.Client.RunCommands([]string{command})
+}
+
+// deployCloudControllerManager stores the cloud configuration files in the
+// manager's secret and deploys it
+func (p *BaseProvider) deployCloudControllerManager(ccm cloudControllerManager, configFiles ...string) error {
+	if ccm.ConfigSecret != "" {
+		if err := p.createSecret(ccm.ConfigSecret, configFiles...); err != nil {
+			return fmt.Errorf("failed to store cloud configuration: %v", err)
+		}
+	}
+
+	manifest, err := renderManifest("cloud-controller-manager", ccm)
+	if err != nil {
+		return err
+	}
+	if err := p.applyManifest(ccm.Name, manifest); err != nil {
+		return fmt.Errorf("failed to deploy %s: %v", ccm.Name, err)
+	}
+	return nil
+}
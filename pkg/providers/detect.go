@@ -0,0 +1,86 @@
+package providers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+)
+
+const (
+	// dmiCommand prints the DMI fields that identify the cloud as key=value lines
+	dmiCommand = `for f in sys_vendor product_name chassis_asset_tag; do echo "$f=$(cat /sys/class/dmi/id/$f 2>/dev/null)"; done`
+
+	// azureAssetTag is the chassis asset tag of every Azure VM, which tells them apart
+	// from other Hyper-V guests
+	azureAssetTag = "7783-7084-3265-9085-8269-3286-77"
+
+	// probeTimeout bounds each metadata service probe, since the link-local address
+	// does not answer at all outside a cloud
+	probeTimeout = 2 * time.Second
+)
+
+// metadataProbes identify a cloud by a request only its metadata service answers
+var metadataProbes = []struct {
+	provider config.CloudProvider
+	request  httpRequest
+}{
+	{config.GCP, httpRequest{URL: gcpMetadataURL, Headers: gcpMetadataHeaders}},
+	{config.Oracle, httpRequest{URL: ociMetadataURL + "instance/id", Headers: ociMetadataHeaders}},
+	{config.Azure, httpRequest{URL: azureMetadataURL + "instance?api-version=2021-02-01", Headers: azureMetadataHeaders}},
+	{config.AWS, httpRequest{
+		Method:  "PUT",
+		URL:     awsMetadataURL + "api/token",
+		Headers: map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"},
+	}},
+}
+
+// Detect identifies the cloud the host runs on from its DMI data, falling back to
+// probing the metadata services. It returns an empty provider for bare metal,
+// private clouds and anything else it does not recognize.
+func Detect(client ssh.Executor) config.CloudProvider {
+	if provider := DetectFromDMI(client); provider != "" {
+		return provider
+	}
+	for _, probe := range metadataProbes {
+		probe.request.Timeout = probeTimeout
+		if _, status, err := doHTTP(client, probe.request); err == nil && status == 200 {
+			return probe.provider
+		}
+	}
+	return ""
+}
+
+// DetectFromDMI identifies the cloud from the DMI data the hypervisor sets, without
+// network requests. It returns an empty provider when the data is not conclusive.
+func DetectFromDMI(client ssh.Executor) config.CloudProvider {
+	output, err := client.RunCommand(dmiCommand)
+	if err != nil {
+		return ""
+	}
+	dmi := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok {
+			dmi[key] = strings.TrimSpace(value)
+		}
+	}
+	return providerFromDMI(dmi)
+}
+
+// providerFromDMI matches the DMI fields against the values each cloud sets
+func providerFromDMI(dmi map[string]string) config.CloudProvider {
+	switch {
+	case dmi["chassis_asset_tag"] == "OracleCloud.com":
+		return config.Oracle
+	case dmi["chassis_asset_tag"] == azureAssetTag:
+		return config.Azure
+	case dmi["sys_vendor"] == "Google" || dmi["product_name"] == "Google Compute Engine":
+		return config.GCP
+	case dmi["sys_vendor"] == "Amazon EC2" || strings.HasPrefix(dmi["chassis_asset_tag"], "Amazon EC2"):
+		return config.AWS
+	}
+	// Older Xen-based EC2 instances report a generic Xen product; the metadata
+	// service identifies them
+	return ""
+}
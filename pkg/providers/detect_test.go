@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+)
+
+func TestProviderFromDMI(t *testing.T) {
+	tests := []struct {
+		name string
+		dmi  map[string]string
+		want config.CloudProvider
+	}{
+		{"AWS Nitro", map[string]string{"sys_vendor": "Amazon EC2", "product_name": "m5.large", "chassis_asset_tag": "Amazon EC2"}, config.AWS},
+		{"AWS bare metal", map[string]string{"sys_vendor": "Amazon EC2", "product_name": "m5.metal"}, config.AWS},
+		{"AWS Xen", map[string]string{"sys_vendor": "Xen", "product_name": "HVM domU", "chassis_asset_tag": "Amazon EC2 i-0123456789abcdef0"}, config.AWS},
+		{"GCP", map[string]string{"sys_vendor": "Google", "product_name": "Google Compute Engine", "chassis_asset_tag": ""}, config.GCP},
+		{"GCP product only", map[string]string{"product_name": "Google Compute Engine"}, config.GCP},
+		{"Azure", map[string]string{"sys_vendor": "Microsoft Corporation", "product_name": "Virtual Machine", "chassis_asset_tag": azureAssetTag}, config.Azure},
+		{"Oracle", map[string]string{"sys_vendor": "QEMU", "product_name": "Standard PC (i440FX + PIIX, 1996)", "chassis_asset_tag": "OracleCloud.com"}, config.Oracle},
+		{"Hyper-V guest", map[string]string{"sys_vendor": "Microsoft Corporation", "product_name": "Virtual Machine", "chassis_asset_tag": "0000-0002-5397-6485-5404-4468-28"}, ""},
+		{"older Xen", map[string]string{"sys_vendor": "Xen", "product_name": "HVM domU"}, ""},
+		{"bare metal", map[string]string{"sys_vendor": "Dell Inc.", "product_name": "PowerEdge R650", "chassis_asset_tag": ""}, ""},
+		{"no DMI data", map[string]string{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := providerFromDMI(tt.dmi); got != tt.want {
+				t.Errorf("providerFromDMI(%v) = %q, want %q", tt.dmi, got, tt.want)
+			}
+		})
+	}
+}
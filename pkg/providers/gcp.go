@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -8,13 +9,24 @@ import (
 	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
 )
 
+const (
+	// gceConfigPath is the GCP cloud controller manager configuration
+	gceConfigPath = "/etc/kubernetes/gce.conf"
+
+	// gcpMetadataURL is the metadata server's v1 API
+	gcpMetadataURL = "http://metadata.google.internal/computeMetadata/v1/"
+)
+
+// gcpMetadataHeaders are required by the metadata server on every request
+var gcpMetadataHeaders = map[string]string{"Metadata-Flavor": "Google"}
+
 // GCPProvider implements the Provider interface for GCP
 type GCPProvider struct {
 	BaseProvider
 }
 
 // NewGCPProvider creates a new GCP provider
-func NewGCPProvider(client *ssh.Client, cfg *config.Config) *GCPProvider {
+func NewGCPProvider(client ssh.Executor, cfg *config.Config) *GCPProvider {
 	return &GCPProvider{
 		BaseProvider: BaseProvider{
 			Client: client,
@@ -23,97 +35,307 @@ func NewGCPProvider(client *ssh.Client, cfg *config.Config) *GCPProvider {
 	}
 }
 
-// GetMetadata retrieves GCP-specific metadata
-func (p *GCPProvider) GetMetadata() (map[string]string, error) {
-	metadata := make(map[string]string)
-
-	// GCP metadata commands
-	commands := []struct {
-		key string
-		cmd string
-	}{
-		{"instance-id", "curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/instance/id"},
-		{"instance-name", "curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/instance/name"},
-		{"zone", "curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/instance/zone | cut -d/ -f4"},
-		{"machine-type", "curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/instance/machine-type | cut -d/ -f4"},
-		{"project-id", "curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/project/project-id"},
-		{"external-ip", "curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/instance/network-interfaces/0/access-configs/0/external-ip"},
-	}
-
-	// Execute each command and store the result
-	for _, cmd := range commands {
-		output, err := p.Client.RunCommand(cmd.cmd)
-		if err == nil {
-			metadata[cmd.key] = output
-		} else {
-			// Don't fail if one metadata command fails, just log it
-			fmt.Printf("Warning: Failed to get GCP metadata '%s': %v\n", cmd.key, err)
-		}
+// gcpInstance is the part of the metadata server's instance document GetMetadata reads
+type gcpInstance struct {
+	ID                json.Number `json:"id"`
+	Name              string      `json:"name"`
+	Hostname          string      `json:"hostname"`
+	MachineType       string      `json:"machineType"`
+	Zone              string      `json:"zone"`
+	Tags              []string    `json:"tags"`
+	NetworkInterfaces []struct {
+		IP            string `json:"ip"`
+		AccessConfigs []struct {
+			ExternalIP string `json:"externalIp"`
+		} `json:"accessConfigs"`
+	} `json:"networkInterfaces"`
+}
+
+// GetMetadata reads the instance document from the metadata server. GCP network
+// tags have no values, so they become tags with empty values.
+func (p *GCPProvider) GetMetadata() (*InstanceMetadata, error) {
+	if p.planning() {
+		return plannedMetadata(), nil
+	}
+
+	var instance gcpInstance
+	if err := p.fetchJSON(gcpMetadataURL+"instance/?recursive=true", gcpMetadataHeaders, &instance); err != nil {
+		return nil, fmt.Errorf("failed to read GCP metadata: %v", err)
 	}
 
-	// Add common metadata
-	commonCommands := baseMetadataCommands()
-	for _, cmd := range commonCommands {
-		output, err := p.Client.RunCommand(cmd)
-		if err == nil {
-			metadata[cmd] = output
+	metadata := &InstanceMetadata{
+		ID:       instance.ID.String(),
+		Name:     instance.Name,
+		Type:     lastSegment(instance.MachineType),
+		Zone:     lastSegment(instance.Zone),
+		Hostname: instance.Name,
+		Tags:     map[string]string{},
+	}
+	// Zones are named after their region, like us-central1-a
+	if n := strings.LastIndex(metadata.Zone, "-"); n > 0 {
+		metadata.Region = metadata.Zone[:n]
+	}
+	// The hostname is fully qualified; nodes register with the instance name
+	if name, _, _ := strings.Cut(instance.Hostname, "."); name != "" {
+		metadata.Hostname = name
+	}
+	for _, nic := range instance.NetworkInterfaces {
+		if nic.IP != "" {
+			metadata.PrivateIPs = append(metadata.PrivateIPs, nic.IP)
+		}
+		for _, access := range nic.AccessConfigs {
+			if access.ExternalIP != "" {
+				metadata.PublicIPs = append(metadata.PublicIPs, access.ExternalIP)
+			}
 		}
 	}
+	for _, tag := range instance.Tags {
+		metadata.Tags[tag] = ""
+	}
 
+	p.metadataDiscovered(metadata)
 	return metadata, nil
 }
 
-// SetupCloudProvider configures the GCP cloud provider integration
+// SetupCloudProvider writes gce.conf from the instance metadata and deploys the GCP
+// cloud controller manager
 func (p *GCPProvider) SetupCloudProvider() error {
 	// Check if VM has the required service account scopes
-	checkScopesCmd := "curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/scopes"
-	scopes, err := p.Client.RunCommand(checkScopesCmd)
+	scopes, err := p.fetch(gcpMetadataURL+"instance/service-accounts/default/scopes", gcpMetadataHeaders)
 	if err != nil {
-		fmt.Println("Warning: Unable to verify service account scopes. Cloud provider integration may not work correctly.")
+		p.Log.Warnf("Unable to verify service account scopes. Cloud provider integration may not work correctly.")
 	} else {
-		if !strings.Contains(scopes, "https://www.googleapis.com/auth/compute") {
-			fmt.Println("Warning: VM service account may not have compute scope. Cloud provider integration may not work correctly.")
-			fmt.Println("         Ensure the VM's service account has the compute.networkUser role.")
+		if !strings.Contains(scopes, "https://www.googleapis.com/auth/compute") && !strings.Contains(scopes, "https://www.googleapis.com/auth/cloud-platform") {
+			p.Log.Warnf("VM service account may not have compute scope. Cloud provider integration may not work correctly.\n         Ensure the VM's service account has the compute.networkUser role.")
 		}
 	}
 
-	// Get project ID
-	projectIDCmd := "curl -s -H 'Metadata-Flavor: Google' http://metadata.google.internal/computeMetadata/v1/project/project-id"
-	projectID, err := p.Client.RunCommand(projectIDCmd)
+	conf, err := p.buildCloudConfig()
 	if err != nil {
-		return fmt.Errorf("failed to get GCP project ID: %v", err)
+		return err
+	}
+	p.checkFirewall(conf)
+
+	if err := p.Client.WriteFile(gceConfigPath, []byte(conf.render()), 0600); err != nil {
+		return err
+	}
+
+	return p.deployCloudControllerManager(cloudControllerManager{
+		Name:    "gcp-cloud-controller-manager",
+		Image:   "registry.k8s.io/cloud-provider-gcp/cloud-controller-manager:" + p.gcpCCMVersion(),
+		Command: []string{"/cloud-controller-manager"},
+		Args: []string{
+			"--v=2",
+			"--cloud-provider=gce",
+			"--cloud-config=/etc/kubernetes/cloud/gce.conf",
+			"--cluster-name=" + p.clusterName(),
+			// Flannel assigns pod CIDRs and routes
+			"--allocate-node-cidrs=false",
+			"--configure-cloud-routes=false",
+			"--use-service-account-credentials=false",
+		},
+		ConfigSecret: "gcp-cloud-provider",
+		ConfigDir:    "/etc/kubernetes/cloud",
+	}, gceConfigPath)
+}
+
+// gceConfig is the [Global] section of gce.conf read by the GCP cloud controller manager
+type gceConfig struct {
+	ProjectID string
+	// NetworkProjectID is the host project of a shared VPC network
+	NetworkProjectID string
+	NetworkName      string
+	SubnetworkName   string
+	// NodeTags are the network tags the manager targets load balancer firewall rules at
+	NodeTags  []string
+	Multizone bool
+}
+
+// render returns the configuration in gcfg format; list values repeat their key
+func (c *gceConfig) render() string {
+	var b strings.Builder
+	b.WriteString("[Global]\n")
+	fmt.Fprintf(&b, "project-id = %s\n", c.ProjectID)
+	if c.NetworkProjectID != "" {
+		fmt.Fprintf(&b, "network-project-id = %s\n", c.NetworkProjectID)
+	}
+	fmt.Fprintf(&b, "network-name = %s\n", c.NetworkName)
+	if c.SubnetworkName != "" {
+		fmt.Fprintf(&b, "subnetwork-name = %s\n", c.SubnetworkName)
 	}
+	for _, tag := range c.NodeTags {
+		fmt.Fprintf(&b, "node-tags = %s\n", tag)
+	}
+	fmt.Fprintf(&b, "multizone = %t\n", c.Multizone)
+	return b.String()
+}
 
-	// GCP cloud provider commands
-	commands := []string{
-		// Create a minimal GCP cloud provider config
-		fmt.Sprintf("cat <<EOF | sudo tee /etc/kubernetes/cloud.conf\n[global]\nproject-id = %s\nnode-tags = k8s-node\nnode-instance-prefix = k8s\nEOF", strings.TrimSpace(projectID)),
-		"sudo chmod 600 /etc/kubernetes/cloud.conf",
-		// Create secret from the cloud.conf file
-		"kubectl -n kube-system create secret generic gcp-cloud-provider --from-file=/etc/kubernetes/cloud.conf || true",
+// buildCloudConfig reads the project, network and network tags from the metadata
+// server, and the subnetwork from the Compute API since metadata does not expose it
+func (p *GCPProvider) buildCloudConfig() (*gceConfig, error) {
+	values := map[string]string{}
+	for _, key := range []string{"project/project-id", "project/numeric-project-id", "instance/network-interfaces/0/network", "instance/zone", "instance/name", "instance/tags"} {
+		output, err := p.fetch(gcpMetadataURL+key, gcpMetadataHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GCP metadata %s: %v", key, err)
+		}
+		values[key] = output
+	}
+
+	conf := &gceConfig{
+		ProjectID: values["project/project-id"],
+		// Nodes may be spread over the zones of the region
+		Multizone: true,
+	}
+
+	// The network is "projects/<number>/networks/<name>"; another project number
+	// means a shared VPC network
+	network := strings.Split(values["instance/network-interfaces/0/network"], "/")
+	conf.NetworkName = network[len(network)-1]
+	if len(network) == 4 && network[1] != values["project/numeric-project-id"] {
+		conf.NetworkProjectID = network[1]
+	}
+
+	if values["instance/tags"] != "" {
+		if err := json.Unmarshal([]byte(values["instance/tags"]), &conf.NodeTags); err != nil {
+			return nil, fmt.Errorf("failed to parse GCP network tags: %v", err)
+		}
 	}
+	if len(conf.NodeTags) == 0 {
+		p.Log.Warnf("Instance %s has no network tags. The cloud controller manager needs them to open load balancer\n         health checks in the firewall; add a tag such as k8s-%s-node to every node.", values["instance/name"], p.clusterName())
+	}
+
+	zone := lastSegment(values["instance/zone"])
+	var instance struct {
+		NetworkInterfaces []struct {
+			Subnetwork string `json:"subnetwork"`
+		} `json:"networkInterfaces"`
+	}
+	err := p.computeAPI(fmt.Sprintf("projects/%s/zones/%s/instances/%s", conf.ProjectID, zone, values["instance/name"]), &instance)
+	if err != nil || len(instance.NetworkInterfaces) == 0 {
+		p.Log.Warnf("Could not read the instance's subnetwork from the Compute API: %v\n         Internal load balancers will use the network's default subnetwork.", err)
+	} else {
+		conf.SubnetworkName = lastSegment(instance.NetworkInterfaces[0].Subnetwork)
+	}
+
+	return conf, nil
+}
+
+// gcpHealthCheckRanges are the source ranges of Google Cloud load balancer health checks
+var gcpHealthCheckRanges = []string{"130.211.0.0/22", "35.191.0.0/16"}
+
+// checkFirewall warns when no firewall rule of the node's network lets load balancer
+// health checks reach instances with the node's network tags. The manager creates
+// such rules for its own load balancers, but only for the tags in gce.conf.
+func (p *GCPProvider) checkFirewall(conf *gceConfig) {
+	var firewalls struct {
+		Items []struct {
+			Name         string   `json:"name"`
+			Network      string   `json:"network"`
+			Direction    string   `json:"direction"`
+			Disabled     bool     `json:"disabled"`
+			SourceRanges []string `json:"sourceRanges"`
+			TargetTags   []string `json:"targetTags"`
+		} `json:"items"`
+	}
+	project := conf.ProjectID
+	if conf.NetworkProjectID != "" {
+		project = conf.NetworkProjectID
+	}
+	if err := p.computeAPI("projects/"+project+"/global/firewalls", &firewalls); err != nil {
+		p.Log.Warnf("Could not check the firewall rules of network %s: %v", conf.NetworkName, err)
+		return
+	}
+
+	var otherTags []string
+	for _, fw := range firewalls.Items {
+		if fw.Disabled || fw.Direction == "EGRESS" || !strings.HasSuffix(fw.Network, "/networks/"+conf.NetworkName) {
+			continue
+		}
+		if !containsAny(fw.SourceRanges, append([]string{"0.0.0.0/0"}, gcpHealthCheckRanges...)) {
+			continue
+		}
+		// Rules without target tags apply to every instance of the network
+		if len(fw.TargetTags) == 0 || containsAny(fw.TargetTags, conf.NodeTags) {
+			return
+		}
+		otherTags = append(otherTags, fw.TargetTags...)
+	}
+
+	if len(otherTags) > 0 {
+		p.Log.Warnf("Firewall rules of network %s allow load balancer health checks only for tags %s, but the node has tags %s",
+			conf.NetworkName, strings.Join(otherTags, ", "), strings.Join(conf.NodeTags, ", "))
+		return
+	}
+	p.Log.Warnf("No firewall rule of network %s allows load balancer health checks from %s to the node's tags.\n         Services of type LoadBalancer with externalTrafficPolicy: Local need one for port 10256.",
+		conf.NetworkName, strings.Join(gcpHealthCheckRanges, " and "))
+}
+
+// computeAPI reads a Compute Engine API resource with the instance service account's
+// token and decodes it into v
+func (p *GCPProvider) computeAPI(resource string, v interface{}) error {
+	token, err := p.accessToken(gcpMetadataURL+"instance/service-accounts/default/token", gcpMetadataHeaders)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	if err := p.fetchJSON("https://compute.googleapis.com/compute/v1/"+resource, headers, v); err != nil {
+		return fmt.Errorf("%s: %v", resource, err)
+	}
+	return nil
+}
+
+// gcpCCMVersion returns the GCP cloud controller manager release for the Kubernetes
+// minor version, which GCP numbers as v<minor>.0.0
+func (p *GCPProvider) gcpCCMVersion() string {
+	minor := strings.TrimPrefix(p.Config.KubernetesMinorVersion(), "v1.")
+	return "v" + minor + ".0.0"
+}
+
+// lastSegment returns the last segment of a resource path like
+// "projects/123/zones/us-central1-a"
+func lastSegment(resource string) string {
+	return resource[strings.LastIndex(resource, "/")+1:]
+}
+
+// containsAny returns true if the lists share an item
+func containsAny(list, items []string) bool {
+	for _, item := range items {
+		for _, entry := range list {
+			if entry == item {
+				return true
+			}
+		}
+	}
+	return false
+}
 
-	return p.Client.RunCommands(commands)
+// CloudProviderInstalled returns true once the cloud controller manager is deployed
+func (p *GCPProvider) CloudProviderInstalled() (bool, error) {
+	return p.installed("kube-system", "daemonset/gcp-cloud-controller-manager")
 }
 
-// GetCloudProviderOptions returns GCP cloud provider-specific options for kubeadm
+// GetCloudProviderOptions returns GCP cloud provider-specific options for kubeadm.
+// The kubelets defer to the cloud controller manager.
 func (p *GCPProvider) GetCloudProviderOptions() string {
-	return "--cloud-provider=gce --cloud-config=/etc/kubernetes/cloud.conf"
+	return externalCloudProvider
 }
 
 // DisplayInfo shows GCP-specific information
 func (p *GCPProvider) DisplayInfo() {
-	fmt.Println("\n====== GCP Cloud Provider Information ======")
-	fmt.Println("For GCP cloud provider integration:")
-	fmt.Println("1. Ensure your VM instance has the following OAuth scopes:")
-	fmt.Println("   - compute-rw")
-	fmt.Println("   - storage-ro")
-	fmt.Println("2. The service account associated with the VM should have:")
-	fmt.Println("   - Compute Admin role")
-	fmt.Println("   - Network Admin role")
-	fmt.Println("3. For load balancers, ensure your network is properly configured with:")
-	fmt.Println("   - Proper firewall rules for health checks (TCP:10256)")
-	fmt.Println("4. For more information, visit:")
-	fmt.Println("   https://kubernetes.io/docs/concepts/cluster-administration/cloud-providers/#gce")
-	fmt.Println("===============================================")
+	p.info(
+		"\n====== GCP Cloud Provider Information ======",
+		"The GCP cloud controller manager runs on the control plane nodes.",
+		"1. Ensure your VM instances have the following OAuth scopes:",
+		"   - compute-rw (or cloud-platform)",
+		"   - storage-ro",
+		"2. The service account associated with the VMs should have:",
+		"   - Compute Admin role",
+		"   - Network Admin role",
+		"3. Give every node the same network tags; they are written to "+gceConfigPath,
+		"   and load balancer firewall rules target them",
+		"4. For more information, visit:",
+		"   https://github.com/kubernetes/cloud-provider-gcp",
+		"===============================================",
+	)
 }
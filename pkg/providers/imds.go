@@ -0,0 +1,118 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+)
+
+const (
+	// awsMetadataURL is the EC2 instance metadata service
+	awsMetadataURL = "http://169.254.169.254/latest/"
+
+	// awsTokenTTL is the lifetime requested for IMDSv2 session tokens, the maximum
+	awsTokenTTL = 6 * time.Hour
+)
+
+// awsMetadataClient reads the EC2 instance metadata service on the host. It uses an
+// IMDSv2 session token, which instances with HttpTokens=required insist on, and
+// keeps it until shortly before it expires. Failed reads are retried with backoff.
+type awsMetadataClient struct {
+	client ssh.Executor
+	// allowV1 falls back to unauthenticated IMDSv1 reads when no token can be obtained
+	allowV1 bool
+	// attempts and delay control the retries; the delay doubles after every attempt
+	attempts int
+	delay    time.Duration
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// newAWSMetadataClient creates a metadata client that falls back to IMDSv1
+func newAWSMetadataClient(client ssh.Executor) *awsMetadataClient {
+	return &awsMetadataClient{
+		client:   client,
+		allowV1:  true,
+		attempts: 3,
+		delay:    time.Second,
+	}
+}
+
+// Get reads a metadata path below "latest/meta-data/", such as "instance-id". A path
+// the instance does not have, like "public-ipv4" without a public address, fails
+// without retrying.
+func (c *awsMetadataClient) Get(path string) (string, error) {
+	var err error
+	delay := c.delay
+	for attempt := 1; attempt <= c.attempts; attempt++ {
+		var output string
+		var status int
+		output, status, err = c.get(path)
+		switch {
+		case err == nil && status == 200:
+			return strings.TrimSpace(output), nil
+		case err == nil && status == 404:
+			return "", fmt.Errorf("EC2 metadata %s not found", path)
+		case err == nil && status == 401:
+			// The token expired or was revoked; the next attempt gets a new one
+			c.mu.Lock()
+			c.token = ""
+			c.mu.Unlock()
+			err = fmt.Errorf("unauthorized")
+		case err == nil:
+			err = fmt.Errorf("HTTP status %d", status)
+		}
+		if attempt < c.attempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return "", fmt.Errorf("failed to read EC2 metadata %s: %v", path, err)
+}
+
+// get makes a single read, with a session token when one can be obtained, and returns
+// the body and HTTP status
+func (c *awsMetadataClient) get(path string) (string, int, error) {
+	token, err := c.sessionToken()
+	if err != nil && !c.allowV1 {
+		return "", 0, err
+	}
+
+	request := httpRequest{URL: awsMetadataURL + "meta-data/" + path}
+	if token != "" {
+		request.Headers = map[string]string{"X-aws-ec2-metadata-token": token}
+	}
+	return doHTTP(c.client, request)
+}
+
+// sessionToken returns the cached IMDSv2 token, requesting a new one when it is
+// missing or about to expire
+func (c *awsMetadataClient) sessionToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	token, status, err := doHTTP(c.client, httpRequest{
+		Method:  "PUT",
+		URL:     awsMetadataURL + "api/token",
+		Headers: map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": fmt.Sprint(int(awsTokenTTL.Seconds()))},
+	})
+	if err == nil && status != 200 {
+		err = fmt.Errorf("HTTP status %d", status)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get IMDSv2 token: %v", err)
+	}
+	c.token = strings.TrimSpace(token)
+	// Renew well before the token expires so that a long installation never uses a stale one
+	c.expires = time.Now().Add(awsTokenTTL - 10*time.Minute)
+	return c.token, nil
+}
@@ -0,0 +1,145 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+)
+
+// InstanceMetadata describes the cloud instance a host runs on, as reported by the
+// provider's instance metadata service
+type InstanceMetadata struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Region     string   `json:"region"`
+	Zone       string   `json:"zone"`
+	PrivateIPs []string `json:"privateIPs"`
+	PublicIPs  []string `json:"publicIPs,omitempty"`
+	// Hostname is the name the node registers with, as the cloud controller manager expects
+	Hostname string            `json:"hostname"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+// plannedMetadata stands in for the metadata of a host that is not contacted
+func plannedMetadata() *InstanceMetadata {
+	return &InstanceMetadata{ID: "<discovered>", Name: "<discovered>", Hostname: "<discovered>"}
+}
+
+// data flattens the metadata into the string map carried by events
+func (m *InstanceMetadata) data() map[string]string {
+	data := map[string]string{
+		"id":          m.ID,
+		"name":        m.Name,
+		"type":        m.Type,
+		"region":      m.Region,
+		"zone":        m.Zone,
+		"private-ips": strings.Join(m.PrivateIPs, ","),
+		"public-ips":  strings.Join(m.PublicIPs, ","),
+		"hostname":    m.Hostname,
+	}
+	tags := make([]string, 0, len(m.Tags))
+	for key, value := range m.Tags {
+		tags = append(tags, key+"="+value)
+	}
+	sort.Strings(tags)
+	data["tags"] = strings.Join(tags, ",")
+	return data
+}
+
+// httpRequest is a request to a metadata service. Metadata services are only
+// reachable from the instance, so requests are made with curl on the host.
+type httpRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	// Timeout limits the whole request when set
+	Timeout time.Duration
+}
+
+// command returns the curl command making the request. The status code is printed
+// on a line after the body.
+func (r httpRequest) command() string {
+	command := "curl -s -w '\\n%{http_code}'"
+	if r.Method != "" && r.Method != "GET" {
+		command += " -X " + r.Method
+	}
+	if r.Timeout > 0 {
+		command += fmt.Sprintf(" -m %g", r.Timeout.Seconds())
+	}
+	names := make([]string, 0, len(r.Headers))
+	for name := range r.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		command += " -H '" + name + ": " + r.Headers[name] + "'"
+	}
+	return command + " '" + r.URL + "'"
+}
+
+// doHTTP makes the request through the executor and returns the body and status code
+func doHTTP(client ssh.Executor, r httpRequest) (string, int, error) {
+	output, err := client.RunCommand(r.command())
+	if err != nil {
+		return "", 0, err
+	}
+
+	output = strings.TrimRight(output, "\n")
+	body, code := "", output
+	if n := strings.LastIndex(output, "\n"); n >= 0 {
+		body, code = output[:n], output[n+1:]
+	}
+	var status int
+	if _, err := fmt.Sscanf(code, "%d", &status); err != nil {
+		if _, planning := client.(*ssh.Recorder); planning {
+			// Recorded commands have no output
+			return "", 200, nil
+		}
+		return "", 0, fmt.Errorf("unexpected response from %s", r.URL)
+	}
+	return body, status, nil
+}
+
+// fetch makes a GET request and returns the body of a successful response
+func (p *BaseProvider) fetch(url string, headers map[string]string) (string, error) {
+	body, status, err := doHTTP(p.Client, httpRequest{URL: url, Headers: headers})
+	if err != nil {
+		return "", err
+	}
+	if status != 200 {
+		return "", fmt.Errorf("%s: HTTP status %d", url, status)
+	}
+	return strings.TrimSpace(body), nil
+}
+
+// fetchJSON makes a GET request and decodes the JSON response into v
+func (p *BaseProvider) fetchJSON(url string, headers map[string]string, v interface{}) error {
+	body, err := p.fetch(url, headers)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(body), v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", url, err)
+	}
+	return nil
+}
+
+// accessToken reads an OAuth access token for the instance's identity from the
+// metadata service's token endpoint
+func (p *BaseProvider) accessToken(url string, headers map[string]string) (string, error) {
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := p.fetchJSON(url, headers, &token); err != nil {
+		return "", fmt.Errorf("failed to get an access token: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("failed to get an access token: %s returned none", url)
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,352 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+)
+
+// fakeHost is an executor that runs the curl commands of metadata requests against a
+// fake metadata server instead of the instance's
+type fakeHost struct {
+	server *httptest.Server
+}
+
+// newFakeHost starts a fake metadata server with the handler
+func newFakeHost(t *testing.T, handler http.HandlerFunc) *fakeHost {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return &fakeHost{server: server}
+}
+
+// RunCommand makes the request of a curl command and prints the body and status code
+// like curl -w '\n%{http_code}' does
+func (h *fakeHost) RunCommand(command string) (string, error) {
+	args := splitCommand(command)
+	if len(args) == 0 || args[0] != "curl" {
+		return "", fmt.Errorf("unexpected command: %s", command)
+	}
+
+	method, url := "GET", ""
+	header := http.Header{}
+	for n := 1; n < len(args); n++ {
+		switch args[n] {
+		case "-s":
+		case "-w", "-m":
+			n++
+		case "-X":
+			n++
+			method = args[n]
+		case "-H":
+			n++
+			name, value, _ := strings.Cut(args[n], ":")
+			header.Set(name, strings.TrimSpace(value))
+		default:
+			url = args[n]
+		}
+	}
+	for _, host := range []string{"http://169.254.169.254", "http://metadata.google.internal"} {
+		url = strings.Replace(url, host, h.server.URL, 1)
+	}
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header = header
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\n%d", body, resp.StatusCode), nil
+}
+
+func (h *fakeHost) RunCommandWithOutput(command string) (string, string, error) {
+	output, err := h.RunCommand(command)
+	return output, "", err
+}
+
+func (h *fakeHost) RunCommands(commands []string) error {
+	return fmt.Errorf("unexpected commands: %v", commands)
+}
+
+func (h *fakeHost) UploadFile(localPath, remotePath string) error {
+	return fmt.Errorf("unexpected upload of %s", localPath)
+}
+
+func (h *fakeHost) WriteFile(remotePath string, content []byte, mode os.FileMode) error {
+	return fmt.Errorf("unexpected write of %s", remotePath)
+}
+
+func (h *fakeHost) CheckCommandExists(command string) bool {
+	return false
+}
+
+func (h *fakeHost) GetRemoteHostname() (string, error) {
+	return "", fmt.Errorf("unexpected hostname lookup")
+}
+
+func (h *fakeHost) Close() error {
+	return nil
+}
+
+// splitCommand splits a command into words, keeping single-quoted text together
+func splitCommand(command string) []string {
+	var words []string
+	var word strings.Builder
+	quoted, inWord := false, false
+	for _, r := range command {
+		switch {
+		case r == '\'':
+			quoted, inWord = !quoted, true
+		case r == ' ' && !quoted:
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			inWord = false
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// routes serves fixed responses by path, requiring the header on every request
+func routes(header, value string, responses map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(header) != value {
+			http.Error(w, "missing "+header, http.StatusUnauthorized)
+			return
+		}
+		response, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, response)
+	}
+}
+
+func checkMetadata(t *testing.T, got, want *InstanceMetadata) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("metadata = %+v, want %+v", got, want)
+	}
+}
+
+func TestAWSMetadata(t *testing.T) {
+	const token = "session-token"
+	responses := map[string]string{
+		"/latest/meta-data/instance-id":                              "i-0123456789abcdef0",
+		"/latest/meta-data/instance-type":                            "m5.large",
+		"/latest/meta-data/placement/region":                         "eu-west-1",
+		"/latest/meta-data/placement/availability-zone":              "eu-west-1a",
+		"/latest/meta-data/local-hostname":                           "ip-10-0-1-23.eu-west-1.compute.internal",
+		"/latest/meta-data/local-ipv4":                               "10.0.1.23",
+		"/latest/meta-data/tags/instance/":                           "Name\nkubernetes.io/cluster/demo",
+		"/latest/meta-data/tags/instance/Name":                       "control-plane-1",
+		"/latest/meta-data/tags/instance/kubernetes.io/cluster/demo": "owned",
+	}
+	tokens := 0
+	host := newFakeHost(t, func(w http.ResponseWriter, r *http.Request) {
+		// IMDSv2 only: every read needs a session token
+		if r.URL.Path == "/latest/api/token" {
+			if r.Method != http.MethodPut || r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+				http.Error(w, "bad token request", http.StatusBadRequest)
+				return
+			}
+			tokens++
+			fmt.Fprint(w, token)
+			return
+		}
+		routes("X-aws-ec2-metadata-token", token, responses)(w, r)
+	})
+
+	p := NewAWSProvider(host, &config.Config{Provider: config.AWS})
+	p.metadata.delay = 0
+	metadata, err := p.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkMetadata(t, metadata, &InstanceMetadata{
+		ID:         "i-0123456789abcdef0",
+		Name:       "control-plane-1",
+		Type:       "m5.large",
+		Region:     "eu-west-1",
+		Zone:       "eu-west-1a",
+		PrivateIPs: []string{"10.0.1.23"},
+		Hostname:   "ip-10-0-1-23.eu-west-1.compute.internal",
+		Tags:       map[string]string{"Name": "control-plane-1", "kubernetes.io/cluster/demo": "owned"},
+	})
+	if tokens != 1 {
+		t.Errorf("requested %d session tokens, want 1", tokens)
+	}
+}
+
+func TestAWSMetadataRetriesUnauthorized(t *testing.T) {
+	requests := 0
+	host := newFakeHost(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			fmt.Fprintf(w, "token-%d", requests)
+			return
+		}
+		requests++
+		// The first token is rejected as if it had expired
+		if r.Header.Get("X-aws-ec2-metadata-token") == "token-0" {
+			http.Error(w, "expired", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "i-0123456789abcdef0")
+	})
+
+	client := newAWSMetadataClient(host)
+	client.delay = 0
+	id, err := client.Get("instance-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "i-0123456789abcdef0" || requests != 2 {
+		t.Errorf("got %q after %d requests, want the instance ID after 2", id, requests)
+	}
+}
+
+func TestGCPMetadata(t *testing.T) {
+	host := newFakeHost(t, routes("Metadata-Flavor", "Google", map[string]string{
+		"/computeMetadata/v1/instance/?recursive=true": `{
+			"id": 4520987212345678901,
+			"name": "worker-1",
+			"hostname": "worker-1.us-central1-a.c.demo-project.internal",
+			"machineType": "projects/123456789012/machineTypes/e2-standard-4",
+			"zone": "projects/123456789012/zones/us-central1-a",
+			"tags": ["k8s-demo-node"],
+			"networkInterfaces": [{
+				"ip": "10.128.0.5",
+				"accessConfigs": [{"externalIp": "34.72.10.20", "type": "ONE_TO_ONE_NAT"}]
+			}]
+		}`,
+	}))
+
+	p := NewGCPProvider(host, &config.Config{Provider: config.GCP})
+	metadata, err := p.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkMetadata(t, metadata, &InstanceMetadata{
+		ID:         "4520987212345678901",
+		Name:       "worker-1",
+		Type:       "e2-standard-4",
+		Region:     "us-central1",
+		Zone:       "us-central1-a",
+		PrivateIPs: []string{"10.128.0.5"},
+		PublicIPs:  []string{"34.72.10.20"},
+		Hostname:   "worker-1",
+		Tags:       map[string]string{"k8s-demo-node": ""},
+	})
+}
+
+func TestAzureMetadata(t *testing.T) {
+	host := newFakeHost(t, routes("Metadata", "true", map[string]string{
+		"/metadata/instance?api-version=2021-02-01": `{
+			"compute": {
+				"vmId": "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+				"name": "control-plane-1",
+				"vmSize": "Standard_D4s_v3",
+				"location": "westeurope",
+				"zone": "2",
+				"tagsList": [{"name": "environment", "value": "test"}]
+			},
+			"network": {
+				"interface": [{
+					"ipv4": {"ipAddress": [{"privateIpAddress": "10.1.0.4", "publicIpAddress": "20.50.1.2"}]}
+				}]
+			}
+		}`,
+	}))
+
+	p := NewAzureProvider(host, &config.Config{Provider: config.Azure})
+	metadata, err := p.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkMetadata(t, metadata, &InstanceMetadata{
+		ID:         "02aab8a4-74ef-476e-8182-f6d2ba4166a6",
+		Name:       "control-plane-1",
+		Type:       "Standard_D4s_v3",
+		Region:     "westeurope",
+		Zone:       "2",
+		PrivateIPs: []string{"10.1.0.4"},
+		PublicIPs:  []string{"20.50.1.2"},
+		Hostname:   "control-plane-1",
+		Tags:       map[string]string{"environment": "test"},
+	})
+}
+
+func TestOracleMetadata(t *testing.T) {
+	host := newFakeHost(t, routes("Authorization", "Bearer Oracle", map[string]string{
+		"/opc/v2/instance/": `{
+			"id": "ocid1.instance.oc1.iad.example",
+			"displayName": "Worker 1",
+			"hostname": "worker-1",
+			"shape": "VM.Standard.E4.Flex",
+			"compartmentId": "ocid1.compartment.oc1..example",
+			"canonicalRegionName": "us-ashburn-1",
+			"availabilityDomain": "Uocm:US-ASHBURN-AD-1",
+			"faultDomain": "FAULT-DOMAIN-2",
+			"freeformTags": {"cluster": "demo"}
+		}`,
+		"/opc/v2/vnics/": `[{
+			"vnicId": "ocid1.vnic.oc1.iad.example",
+			"privateIp": "10.0.10.7",
+			"subnetCidrBlock": "10.0.10.0/24",
+			"macAddr": "02:00:17:00:00:01"
+		}]`,
+	}))
+
+	p := NewOracleProvider(host, &config.Config{Provider: config.Oracle})
+	metadata, err := p.GetMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkMetadata(t, metadata, &InstanceMetadata{
+		ID:         "ocid1.instance.oc1.iad.example",
+		Name:       "Worker 1",
+		Type:       "VM.Standard.E4.Flex",
+		Region:     "us-ashburn-1",
+		Zone:       "Uocm:US-ASHBURN-AD-1",
+		PrivateIPs: []string{"10.0.10.7"},
+		Hostname:   "worker-1",
+		Tags:       map[string]string{"cluster": "demo"},
+	})
+}
+
+func TestMetadataServiceErrors(t *testing.T) {
+	host := newFakeHost(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	p := NewGCPProvider(host, &config.Config{Provider: config.GCP})
+	if _, err := p.GetMetadata(); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("GetMetadata() error = %v, want HTTP status 503", err)
+	}
+}
@@ -1,10 +1,22 @@
 package providers
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
 	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ociConfigPath is the OCI cloud controller manager configuration
+	ociConfigPath = "/etc/kubernetes/cloud-provider.yaml"
+
+	// ociImage provides both the cloud controller manager and the CSI driver
+	ociImage = "ghcr.io/oracle/cloud-provider-oci"
 )
 
 // OracleProvider implements the Provider interface for Oracle Cloud
@@ -13,7 +25,7 @@ type OracleProvider struct {
 }
 
 // NewOracleProvider creates a new Oracle provider
-func NewOracleProvider(client *ssh.Client, cfg *config.Config) *OracleProvider {
+func NewOracleProvider(client ssh.Executor, cfg *config.Config) *OracleProvider {
 	return &OracleProvider{
 		BaseProvider: BaseProvider{
 			Client: client,
@@ -22,81 +34,252 @@ func NewOracleProvider(client *ssh.Client, cfg *config.Config) *OracleProvider {
 	}
 }
 
-// GetMetadata retrieves Oracle Cloud-specific metadata
-func (p *OracleProvider) GetMetadata() (map[string]string, error) {
-	metadata := make(map[string]string)
-
-	// Oracle Cloud doesn't have a standard metadata service like other providers
-	// So we use basic system commands to gather information
-	commands := []struct {
-		key string
-		cmd string
-	}{
-		{"hostname", "hostname"},
-		{"ip-addr", "ip addr show | grep 'inet ' | grep -v '127.0.0.1' | awk '{print $2}' | cut -d/ -f1"},
-		{"os-version", "cat /etc/os-release | grep PRETTY_NAME | cut -d= -f2 | tr -d '\"'"},
-	}
-
-	// Execute each command and store the result
-	for _, cmd := range commands {
-		output, err := p.Client.RunCommand(cmd.cmd)
-		if err == nil {
-			metadata[cmd.key] = output
-		} else {
-			// Don't fail if one metadata command fails, just log it
-			fmt.Printf("Warning: Failed to get Oracle Cloud metadata '%s': %v\n", cmd.key, err)
-		}
+// GetMetadata reads the instance document and VNICs from the instance metadata
+// service. OCI zones are availability domains; the metadata service does not know
+// public addresses.
+func (p *OracleProvider) GetMetadata() (*InstanceMetadata, error) {
+	if p.planning() {
+		return plannedMetadata(), nil
+	}
+
+	instance, err := p.instanceMetadata()
+	if err != nil {
+		return nil, err
+	}
+	metadata := &InstanceMetadata{
+		ID:     instance.ID,
+		Name:   instance.DisplayName,
+		Type:   instance.Shape,
+		Region: instance.CanonicalRegionName,
+		Zone:   instance.AvailabilityDomain,
+		// The cloud controller manager matches nodes against the hostname label
+		Hostname: instance.Hostname,
+		Tags:     instance.FreeformTags,
 	}
 
-	// Add common metadata
-	commonCommands := baseMetadataCommands()
-	for _, cmd := range commonCommands {
-		output, err := p.Client.RunCommand(cmd)
-		if err == nil {
-			metadata[cmd] = output
+	vnics, err := p.vnics()
+	if err != nil {
+		// Don't fail if the VNICs cannot be read, just log it
+		p.Log.Warnf("Failed to get Oracle Cloud VNIC metadata: %v", err)
+	}
+	for _, vnic := range vnics {
+		if vnic.PrivateIP != "" {
+			metadata.PrivateIPs = append(metadata.PrivateIPs, vnic.PrivateIP)
 		}
 	}
 
+	p.metadataDiscovered(metadata)
 	return metadata, nil
 }
 
-// SetupCloudProvider configures the Oracle Cloud provider integration
+// SetupCloudProvider renders the OCI cloud controller manager configuration from the
+// instance metadata and deploys the manager and the block volume CSI driver. Both
+// authenticate as the instance through instance principals.
 func (p *OracleProvider) SetupCloudProvider() error {
-	// Oracle Cloud doesn't have a native Kubernetes cloud provider
-	// So we just display information about the Oracle Cloud Controller Manager
-	fmt.Println("Oracle Cloud doesn't have a native Kubernetes cloud provider integration.")
-	fmt.Println("For load balancer and volume provisioning support, please install the Oracle Cloud Controller Manager separately.")
-	fmt.Println("See: https://github.com/oracle/oci-cloud-controller-manager")
+	conf, err := p.buildCloudConfig()
+	if err != nil {
+		return err
+	}
+	data, err := conf.render()
+	if err != nil {
+		return err
+	}
+	if err := p.Client.WriteFile(ociConfigPath, data, 0600); err != nil {
+		return err
+	}
 
-	// We'll just create a placeholder config file
-	commands := []string{
-		// Create a placeholder cloud provider config
-		"cat <<EOF | sudo tee /etc/kubernetes/oci.conf\n# Oracle Cloud configuration\n# See https://github.com/oracle/oci-cloud-controller-manager for more information\nEOF",
-		"sudo chmod 600 /etc/kubernetes/oci.conf",
+	err = p.deployCloudControllerManager(cloudControllerManager{
+		Name:    "oci-cloud-controller-manager",
+		Image:   ociImage + ":" + p.ccmVersion(),
+		Command: []string{"/usr/local/bin/oci-cloud-controller-manager"},
+		Args: []string{
+			"--v=2",
+			"--cloud-provider=oci",
+			"--cloud-config=/etc/oci/cloud-provider.yaml",
+			"--cluster-name=" + p.clusterName(),
+			"--leader-elect-resource-lock=leases",
+			"--concurrent-service-syncs=3",
+		},
+		ConfigSecret: "oci-cloud-controller-manager",
+		ConfigDir:    "/etc/oci",
+	}, ociConfigPath)
+	if err != nil {
+		return err
+	}
+
+	// The CSI driver reads the same configuration under another name
+	if err := p.createSecret("oci-volume-provisioner", "config.yaml="+ociConfigPath); err != nil {
+		return fmt.Errorf("failed to store block volume configuration: %v", err)
+	}
+	manifest, err := renderManifest("oci-csi-driver", map[string]string{
+		"Image": ociImage + ":" + p.ccmVersion(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.applyManifest("oci-csi-driver", manifest); err != nil {
+		return fmt.Errorf("failed to deploy the OCI block volume CSI driver: %v", err)
+	}
+	return nil
+}
+
+// ociConfig is the cloud-provider.yaml read by the OCI cloud controller manager
+type ociConfig struct {
+	UseInstancePrincipals bool            `yaml:"useInstancePrincipals"`
+	Compartment           string          `yaml:"compartment"`
+	VCN                   string          `yaml:"vcn"`
+	LoadBalancer          ociLoadBalancer `yaml:"loadBalancer"`
+}
+
+// ociLoadBalancer places the load balancers of LoadBalancer services
+type ociLoadBalancer struct {
+	Subnet1                    string `yaml:"subnet1"`
+	Subnet2                    string `yaml:"subnet2,omitempty"`
+	SecurityListManagementMode string `yaml:"securityListManagementMode"`
+}
+
+// render validates the configuration and returns it as YAML
+func (c *ociConfig) render() ([]byte, error) {
+	var missing []string
+	for name, value := range map[string]string{"compartment": c.Compartment, "vcn": c.VCN, "loadBalancerSubnets": c.LoadBalancer.Subnet1} {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("OCI cloud provider configuration is missing %s: set them in the \"oracle\" section of the config file", strings.Join(missing, ", "))
+	}
+	switch c.LoadBalancer.SecurityListManagementMode {
+	case "All", "Frontend", "None":
+	default:
+		return nil, fmt.Errorf("invalid OCI securityListManagementMode '%s': use All, Frontend or None", c.LoadBalancer.SecurityListManagementMode)
 	}
 
-	return p.Client.RunCommands(commands)
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, fmt.Errorf("failed to render OCI cloud provider configuration: %v", err)
+	}
+	encoder.Close()
+	return buf.Bytes(), nil
+}
+
+// buildCloudConfig reads the compartment from the instance metadata and applies the
+// network settings from the configuration. The metadata service does not name the
+// VCN or subnets, so those must be configured.
+func (p *OracleProvider) buildCloudConfig() (*ociConfig, error) {
+	settings := p.Config.Oracle
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	conf := &ociConfig{
+		UseInstancePrincipals: true,
+		VCN:                   settings.VCN,
+		LoadBalancer: ociLoadBalancer{
+			Subnet1:                    settings.LoadBalancerSubnets[0],
+			SecurityListManagementMode: "All",
+		},
+	}
+	if len(settings.LoadBalancerSubnets) > 1 {
+		conf.LoadBalancer.Subnet2 = settings.LoadBalancerSubnets[1]
+	}
+	if settings.SecurityListManagementMode != "" {
+		conf.LoadBalancer.SecurityListManagementMode = settings.SecurityListManagementMode
+	}
+
+	conf.Compartment = settings.Compartment
+	if conf.Compartment == "" {
+		if p.planning() {
+			// Nothing can be discovered without a host
+			conf.Compartment = "<discovered>"
+		} else {
+			instance, err := p.instanceMetadata()
+			if err != nil {
+				return nil, err
+			}
+			conf.Compartment = instance.CompartmentID
+			p.Log.Infof("OCI instance %s in %s (%s)", instance.DisplayName, instance.CanonicalRegionName, instance.AvailabilityDomain)
+		}
+	}
+	return conf, nil
+}
+
+// ociMetadataURL is version 2 of the OCI instance metadata service, which requires the
+// "Authorization: Bearer Oracle" header; version 1 may be disabled on the instance
+const ociMetadataURL = "http://169.254.169.254/opc/v2/"
+
+// ociMetadataHeaders authenticate requests to version 2 of the metadata service
+var ociMetadataHeaders = map[string]string{"Authorization": "Bearer Oracle"}
+
+// ociInstance is the subset of the OCI instance metadata that is read
+type ociInstance struct {
+	ID                  string            `json:"id"`
+	DisplayName         string            `json:"displayName"`
+	Hostname            string            `json:"hostname"`
+	Shape               string            `json:"shape"`
+	CompartmentID       string            `json:"compartmentId"`
+	CanonicalRegionName string            `json:"canonicalRegionName"`
+	AvailabilityDomain  string            `json:"availabilityDomain"`
+	FaultDomain         string            `json:"faultDomain"`
+	FreeformTags        map[string]string `json:"freeformTags"`
+}
+
+// ociVnic is a VNIC attached to the instance; the first one is the primary VNIC
+type ociVnic struct {
+	PrivateIP       string `json:"privateIp"`
+	SubnetCIDRBlock string `json:"subnetCidrBlock"`
+	MacAddr         string `json:"macAddr"`
+}
+
+// instanceMetadata reads the instance document from the metadata service
+func (p *OracleProvider) instanceMetadata() (*ociInstance, error) {
+	var instance ociInstance
+	if err := p.fetchJSON(ociMetadataURL+"instance/", ociMetadataHeaders, &instance); err != nil {
+		return nil, fmt.Errorf("failed to read OCI instance metadata: %v", err)
+	}
+	return &instance, nil
+}
+
+// vnics reads the VNICs attached to the instance from the metadata service
+func (p *OracleProvider) vnics() ([]ociVnic, error) {
+	var vnics []ociVnic
+	if err := p.fetchJSON(ociMetadataURL+"vnics/", ociMetadataHeaders, &vnics); err != nil {
+		return nil, fmt.Errorf("failed to read OCI VNIC metadata: %v", err)
+	}
+	return vnics, nil
+}
+
+// CloudProviderInstalled returns true once the cloud controller manager and the
+// block volume CSI driver are deployed
+func (p *OracleProvider) CloudProviderInstalled() (bool, error) {
+	return p.installed("kube-system", "daemonset/oci-cloud-controller-manager", "deployment/csi-oci-controller", "daemonset/csi-oci-node")
 }
 
-// GetCloudProviderOptions returns Oracle Cloud provider-specific options for kubeadm
-// Oracle Cloud doesn't have a native cloud provider, so this is empty
+// GetCloudProviderOptions returns Oracle Cloud provider-specific options for kubeadm.
+// The kubelets defer to the OCI cloud controller manager.
 func (p *OracleProvider) GetCloudProviderOptions() string {
-	return ""
+	return externalCloudProvider
 }
 
 // DisplayInfo shows Oracle Cloud-specific information
 func (p *OracleProvider) DisplayInfo() {
-	fmt.Println("\n====== Oracle Cloud Information ======")
-	fmt.Println("For Oracle Cloud integration:")
-	fmt.Println("1. Oracle Cloud doesn't have a native Kubernetes cloud provider.")
-	fmt.Println("2. For load balancer and volume provisioning support:")
-	fmt.Println("   - Install the Oracle Cloud Controller Manager from: https://github.com/oracle/oci-cloud-controller-manager")
-	fmt.Println("   - Follow the instructions to create a configuration file with the required OCI credentials")
-	fmt.Println("3. To set up cloud storage:")
-	fmt.Println("   - Install the Oracle Cloud Storage Provisioner: https://github.com/oracle/oci-cloud-controller-manager/blob/master/docs/volume-provisioner.md")
-	fmt.Println("4. For networking, ensure your security lists allow:")
-	fmt.Println("   - Pod-to-Pod communication")
-	fmt.Println("   - NodePort services (30000-32767)")
-	fmt.Println("   - Control plane communication (6443, 10250-10252)")
-	fmt.Println("===========================================")
+	p.info(
+		"\n====== Oracle Cloud Information ======",
+		"The OCI cloud controller manager runs on the control plane nodes, and the",
+		"block volume CSI driver provides the oci-bv storage class.",
+		"1. Both authenticate as the instances, so create a dynamic group matching them",
+		"   and allow it to manage load-balancers, volume-family and",
+		"   virtual-network-family in the compartment",
+		"2. Load balancers are placed in the subnets from the \"oracle\" section of",
+		"   the config file",
+		"3. For networking, ensure your security lists allow:",
+		"   - Pod-to-Pod communication",
+		"   - NodePort services (30000-32767)",
+		"   - Control plane communication (6443, 10250-10252)",
+		"4. For more information, visit:",
+		"   https://github.com/oracle/oci-cloud-controller-manager",
+		"===========================================",
+	)
 }
@@ -1,18 +1,24 @@
 package providers
 
 import (
+	"strings"
+
 	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
 	"github.com/ochestra-tech/kubeforge-cli/pkg/ssh"
 )
 
 // Provider defines the interface for cloud provider-specific operations
 type Provider interface {
-	// GetMetadata retrieves cloud provider-specific metadata
-	GetMetadata() (map[string]string, error)
+	// GetMetadata reads the instance metadata of the host
+	GetMetadata() (*InstanceMetadata, error)
 
 	// SetupCloudProvider configures the Kubernetes cloud provider integration
 	SetupCloudProvider() error
 
+	// CloudProviderInstalled probes the cluster for what SetupCloudProvider deploys
+	CloudProviderInstalled() (bool, error)
+
 	// GetCloudProviderOptions returns cloud provider-specific options for kubeadm
 	GetCloudProviderOptions() string
 
@@ -20,8 +26,16 @@ type Provider interface {
 	DisplayInfo()
 }
 
-// NewProvider creates a new cloud provider based on the configuration
-func NewProvider(client *ssh.Client, cfg *config.Config) Provider {
+// NodeIPProvider is implemented by providers that choose the address nodes register
+// with, where no cloud controller manager reports node addresses
+type NodeIPProvider interface {
+	// NodeIP returns the address the kubelet and API server use
+	NodeIP() (string, error)
+}
+
+// NewProvider creates a new cloud provider based on the configuration. Providers
+// that are not known, including auto before detection, get no cloud integration.
+func NewProvider(client ssh.Executor, cfg *config.Config) Provider {
 	switch cfg.Provider {
 	case config.AWS:
 		return NewAWSProvider(client, cfg)
@@ -32,23 +46,36 @@ func NewProvider(client *ssh.Client, cfg *config.Config) Provider {
 	case config.Oracle:
 		return NewOracleProvider(client, cfg)
 	default:
-		// Default to AWS
-		return NewAWSProvider(client, cfg)
+		return NewBareMetalProvider(client, cfg)
 	}
 }
 
 // BaseProvider implements common functionality for all providers
 type BaseProvider struct {
-	Client *ssh.Client
+	Client ssh.Executor
 	Config *config.Config
+	// Log receives warnings, discovered metadata and provider information; nil discards them
+	Log *events.Logger
 }
 
-// baseMetadataCommands returns common commands for all providers
-func baseMetadataCommands() []string {
-	return []string{
-		"hostname",
-		"uname -a",
-		"lscpu | grep '^CPU(s):'",
-		"free -m | grep '^Mem:'",
-	}
+// SetLogger sets where warnings and provider information are emitted
+func (p *BaseProvider) SetLogger(l *events.Logger) {
+	p.Log = l
+}
+
+// info emits a block of provider information as one event
+func (p *BaseProvider) info(lines ...string) {
+	p.Log.Emit(events.Event{Type: events.Info, Message: strings.Join(lines, "\n")})
+}
+
+// metadataDiscovered emits the metadata read from the instance
+func (p *BaseProvider) metadataDiscovered(metadata *InstanceMetadata) {
+	p.Log.Emit(events.Event{Type: events.MetadataDiscovered, Message: string(p.Config.Provider), Data: metadata.data()})
+}
+
+// planning returns true if commands are recorded for a plan instead of run, so
+// nothing can be discovered from the host
+func (p *BaseProvider) planning() bool {
+	_, ok := p.Client.(*ssh.Recorder)
+	return ok
 }
@@ -5,21 +5,59 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"os"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/ochestra-tech/kubeforge-cli/pkg/config"
+	"github.com/ochestra-tech/kubeforge-cli/pkg/events"
 	"golang.org/x/crypto/ssh"
 )
 
+// Executor runs commands and writes files on a remote host
+type Executor interface {
+	// RunCommand executes a command and returns its standard output
+	RunCommand(command string) (string, error)
+
+	// RunCommandWithOutput executes a command and returns both stdout and stderr
+	RunCommandWithOutput(command string) (string, string, error)
+
+	// RunCommands executes multiple commands sequentially
+	RunCommands(commands []string) error
+
+	// UploadFile uploads a local file to the remote host
+	UploadFile(localPath, remotePath string) error
+
+	// WriteFile writes content to a root-owned file on the remote host
+	WriteFile(remotePath string, content []byte, mode os.FileMode) error
+
+	// CheckCommandExists checks if a command exists on the remote host
+	CheckCommandExists(command string) bool
+
+	// GetRemoteHostname gets the hostname of the remote host
+	GetRemoteHostname() (string, error)
+
+	// Close releases the connection
+	Close() error
+}
+
 // Client represents an SSH client connection
 type Client struct {
 	config *config.Config
 	client *ssh.Client
+	log    *events.Logger
+	// jumps are the connections to the jump hosts the client is reached through
+	jumps []*ssh.Client
 }
 
 // NewClient creates a new SSH client using the provided configuration
 func NewClient(cfg *config.Config) (*Client, error) {
+	if cfg.PrivateKey == "" && cfg.Password == "" {
+		return nil, fmt.Errorf("either private key or password is required")
+	}
+
 	var authMethod ssh.AuthMethod
 
 	if cfg.PrivateKey != "" {
@@ -46,8 +84,8 @@ func NewClient(cfg *config.Config) (*Client, error) {
 		Timeout:         15 * time.Second,
 	}
 
-	addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
-	client, err := ssh.Dial("tcp", addr, sshConfig)
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	client, jumps, err := dialJumps(cfg.JumpHosts, addr, sshConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to host: %v", err)
 	}
@@ -55,9 +93,15 @@ func NewClient(cfg *config.Config) (*Client, error) {
 	return &Client{
 		config: cfg,
 		client: client,
+		jumps:  jumps,
 	}, nil
 }
 
+// SetLogger sets where the commands and files being run are reported
+func (c *Client) SetLogger(l *events.Logger) {
+	c.log = l
+}
+
 // RunCommand executes a command on the remote host
 func (c *Client) RunCommand(command string) (string, error) {
 	session, err := c.client.NewSession()
@@ -104,7 +148,7 @@ func (c *Client) RunCommandWithOutput(command string) (string, string, error) {
 // RunCommands executes multiple commands sequentially
 func (c *Client) RunCommands(commands []string) error {
 	for _, cmd := range commands {
-		fmt.Printf("  Running: %s\n", cmd)
+		c.log.Command(Redact(cmd))
 		_, err := c.RunCommand(cmd)
 		if err != nil {
 			return err
@@ -159,6 +203,33 @@ func (c *Client) UploadFile(localPath, remotePath string) error {
 	return nil
 }
 
+// WriteFile writes content to a root-owned file on the remote host, creating the
+// parent directory if needed. install creates the file with its final mode, so
+// secrets are never readable by other users in between.
+func (c *Client) WriteFile(remotePath string, content []byte, mode os.FileMode) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stdin = bytes.NewReader(content)
+	session.Stderr = &stderr
+
+	cmd := fmt.Sprintf("sudo mkdir -p %s && sudo install -m %o /dev/stdin %s",
+		path.Dir(remotePath), mode.Perm(), remotePath)
+	c.log.Emit(events.Event{Type: events.FileWritten, Command: remotePath})
+	if err := session.Run(cmd); err != nil {
+		if errMsg := stderr.String(); errMsg != "" {
+			return fmt.Errorf("failed to write %s: %v\nError output: %s", remotePath, err, errMsg)
+		}
+		return fmt.Errorf("failed to write %s: %v", remotePath, err)
+	}
+
+	return nil
+}
+
 // CheckCommandExists checks if a command exists on the remote host
 func (c *Client) CheckCommandExists(command string) bool {
 	cmd := fmt.Sprintf("command -v %s", command)
@@ -175,7 +246,11 @@ func (c *Client) GetRemoteHostname() (string, error) {
 	return strings.TrimSpace(output), nil
 }
 
-// Close closes the SSH client connection
+// Close closes the SSH client connection and those to its jump hosts
 func (c *Client) Close() error {
-	return c.client.Close()
+	err := c.client.Close()
+	for n := len(c.jumps) - 1; n >= 0; n-- {
+		c.jumps[n].Close()
+	}
+	return err
 }
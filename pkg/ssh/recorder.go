@@ -0,0 +1,148 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// secretPatterns match credentials that must never appear in recorded output
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(--token[ =])\S+`),
+	regexp.MustCompile(`(--discovery-token-ca-cert-hash[ =])\S+`),
+	regexp.MustCompile(`(--certificate-key[ =])\S+`),
+	regexp.MustCompile(`(Authorization: Bearer )[^'\s]+`),
+	regexp.MustCompile(`(?i)("?(?:password|secret|aadClientSecret|token|certificateKey)"?\s*[:=]\s*"?)[^"\s,]+`),
+}
+
+// RecordedCommand is a command captured by the Recorder
+type RecordedCommand struct {
+	Step    string `json:"step"`
+	Command string `json:"command"`
+}
+
+// RecordedFile is a file write captured by the Recorder
+type RecordedFile struct {
+	Step    string      `json:"step"`
+	Path    string      `json:"path"`
+	Mode    os.FileMode `json:"mode"`
+	Content string      `json:"content"`
+	// Source is the local path for uploaded files
+	Source string `json:"source,omitempty"`
+}
+
+// Recorder implements the Executor interface by recording commands and file writes
+// instead of running them. Commands succeed with empty output, so the installer and
+// providers follow their normal path.
+type Recorder struct {
+	// Secrets are literal values redacted from everything that is recorded
+	Secrets []string
+
+	step     string
+	commands []RecordedCommand
+	files    []RecordedFile
+}
+
+// NewRecorder creates a new recording executor
+func NewRecorder(secrets ...string) *Recorder {
+	return &Recorder{Secrets: secrets}
+}
+
+// SetStep labels subsequently recorded commands and files with a step name
+func (r *Recorder) SetStep(step string) {
+	r.step = step
+}
+
+// Commands returns the recorded commands in order
+func (r *Recorder) Commands() []RecordedCommand {
+	return r.commands
+}
+
+// Files returns the recorded file writes in order
+func (r *Recorder) Files() []RecordedFile {
+	return r.files
+}
+
+// Redact replaces known secrets and credential-like values with a placeholder
+func (r *Recorder) Redact(text string) string {
+	for _, secret := range r.Secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, "<redacted>")
+		}
+	}
+	return Redact(text)
+}
+
+// Redact replaces credential-like values such as join tokens with a placeholder
+func Redact(text string) string {
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllString(text, "${1}<redacted>")
+	}
+	return text
+}
+
+// RunCommand records a command
+func (r *Recorder) RunCommand(command string) (string, error) {
+	r.commands = append(r.commands, RecordedCommand{Step: r.step, Command: r.Redact(command)})
+	return "", nil
+}
+
+// RunCommandWithOutput records a command
+func (r *Recorder) RunCommandWithOutput(command string) (string, string, error) {
+	output, err := r.RunCommand(command)
+	return output, "", err
+}
+
+// RunCommands records multiple commands
+func (r *Recorder) RunCommands(commands []string) error {
+	for _, cmd := range commands {
+		if _, err := r.RunCommand(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UploadFile records a file upload with the local file's content
+func (r *Recorder) UploadFile(localPath, remotePath string) error {
+	content, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read local file: %v", err)
+	}
+	r.files = append(r.files, RecordedFile{
+		Step:    r.step,
+		Path:    remotePath,
+		Mode:    0644,
+		Content: r.Redact(string(content)),
+		Source:  localPath,
+	})
+	return nil
+}
+
+// WriteFile records a file write
+func (r *Recorder) WriteFile(remotePath string, content []byte, mode os.FileMode) error {
+	r.files = append(r.files, RecordedFile{
+		Step:    r.step,
+		Path:    remotePath,
+		Mode:    mode,
+		Content: r.Redact(string(content)),
+	})
+	return nil
+}
+
+// CheckCommandExists records the check and reports the command as present
+func (r *Recorder) CheckCommandExists(command string) bool {
+	_, err := r.RunCommand(fmt.Sprintf("command -v %s", command))
+	return err == nil
+}
+
+// GetRemoteHostname returns a placeholder hostname
+func (r *Recorder) GetRemoteHostname() (string, error) {
+	return "<hostname>", nil
+}
+
+// Close does nothing for a recorder
+func (r *Recorder) Close() error {
+	return nil
+}
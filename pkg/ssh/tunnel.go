@@ -0,0 +1,184 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Tunnel forwards connections accepted on a local address to a remote address
+// through the SSH connection, like "ssh -L"
+type Tunnel struct {
+	listener net.Listener
+	client   *ssh.Client
+	remote   string
+
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+	wg    sync.WaitGroup
+}
+
+// Forward listens on the local address and forwards every connection to the remote
+// address as seen from the SSH host. A local port of 0 picks a free port.
+func (c *Client) Forward(localAddr, remoteAddr string) (*Tunnel, error) {
+	listener, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", localAddr, err)
+	}
+
+	t := &Tunnel{
+		listener: listener,
+		client:   c.client,
+		remote:   remoteAddr,
+		conns:    make(map[net.Conn]bool),
+	}
+	t.wg.Add(1)
+	go t.serve()
+	return t, nil
+}
+
+// Addr returns the local address of the tunnel
+func (t *Tunnel) Addr() string {
+	return t.listener.Addr().String()
+}
+
+// Close stops accepting connections and closes the forwarded ones. The SSH
+// connection stays open.
+func (t *Tunnel) Close() error {
+	err := t.listener.Close()
+
+	t.mu.Lock()
+	for conn := range t.conns {
+		conn.Close()
+	}
+	t.conns = nil
+	t.mu.Unlock()
+
+	t.wg.Wait()
+	return err
+}
+
+// serve accepts local connections until the listener is closed
+func (t *Tunnel) serve() {
+	defer t.wg.Done()
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		t.wg.Add(1)
+		go t.forward(local)
+	}
+}
+
+// forward copies data between a local connection and a new remote one
+func (t *Tunnel) forward(local net.Conn) {
+	defer t.wg.Done()
+
+	remote, err := t.client.Dial("tcp", t.remote)
+	if err != nil {
+		local.Close()
+		return
+	}
+
+	if !t.track(local, remote) {
+		return
+	}
+	defer t.untrack(local, remote)
+
+	done := make(chan struct{}, 2)
+	copyConn := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go copyConn(remote, local)
+	go copyConn(local, remote)
+	// Either side finishing ends the forwarded connection
+	<-done
+}
+
+// track records open connections so that Close can end them. It returns false and
+// closes them if the tunnel is already closed.
+func (t *Tunnel) track(conns ...net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns == nil {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		return false
+	}
+	for _, conn := range conns {
+		t.conns[conn] = true
+	}
+	return true
+}
+
+// untrack closes the connections and forgets them
+func (t *Tunnel) untrack(conns ...net.Conn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+		delete(t.conns, conn)
+	}
+}
+
+// dialJumps connects to the target address through a chain of jump hosts given as
+// "[user@]host[:port]", like "ssh -J". Jump hosts use the target's credentials and
+// default to its user and port 22. The returned jump clients must be closed in
+// reverse order after the target client.
+func dialJumps(jumps []string, targetAddr string, sshConfig *ssh.ClientConfig) (*ssh.Client, []*ssh.Client, error) {
+	var chain []*ssh.Client
+	closeChain := func() {
+		for n := len(chain) - 1; n >= 0; n-- {
+			chain[n].Close()
+		}
+	}
+
+	// The first hop is dialled directly and every later one through the previous hop
+	dial := func(addr string, hopConfig *ssh.ClientConfig) (*ssh.Client, error) {
+		if len(chain) == 0 {
+			return ssh.Dial("tcp", addr, hopConfig)
+		}
+		conn, err := chain[len(chain)-1].Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, hopConfig)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return ssh.NewClient(clientConn, chans, reqs), nil
+	}
+
+	for _, jump := range jumps {
+		hopConfig := *sshConfig
+		host := jump
+		if at := strings.LastIndex(jump, "@"); at >= 0 {
+			hopConfig.User, host = jump[:at], jump[at+1:]
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "22")
+		}
+
+		client, err := dial(host, &hopConfig)
+		if err != nil {
+			closeChain()
+			return nil, nil, fmt.Errorf("failed to connect to jump host %s: %v", host, err)
+		}
+		chain = append(chain, client)
+	}
+
+	client, err := dial(targetAddr, sshConfig)
+	if err != nil {
+		closeChain()
+		return nil, nil, err
+	}
+	return client, chain, nil
+}